@@ -0,0 +1,36 @@
+package enproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMarkActiveUpdatesLastActivity confirms markActive's atomic timestamp
+// is visible via lastActivity without requiring connInfoMutex, and that an
+// unconnected Conn reports the zero time.
+func TestMarkActiveUpdatesLastActivity(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	assert.True(t, conn.lastActivity().IsZero(), "expected no activity before markActive is ever called")
+
+	before := time.Now()
+	conn.markActive()
+	assert.False(t, conn.lastActivity().Before(before))
+}
+
+// BenchmarkMarkActive exercises the hot path every read and write takes,
+// concurrently from multiple goroutines, to confirm the atomic timestamp
+// doesn't serialize callers the way a mutex would.
+func BenchmarkMarkActive(b *testing.B) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			conn.markActive()
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package enproxy
+
+import "fmt"
+
+// AuthFailed is returned when the proxy keeps responding 407 past
+// c.maxAuthRounds() rounds of Config.AuthHandler.
+type AuthFailed struct {
+	// Rounds is how many rounds of Config.AuthHandler were attempted
+	// before giving up.
+	Rounds int
+}
+
+func (e *AuthFailed) Error() string {
+	return fmt.Sprintf("proxy rejected our credentials after %d rounds of authentication, giving up", e.Rounds)
+}
+
+// defaultMaxAuthRounds bounds how many rounds of Config.AuthHandler a
+// single request will go through when Config.MaxAuthRounds is unset.
+const defaultMaxAuthRounds = 5
+
+// maxAuthRounds returns the configured round budget, or the package
+// default if unset.
+func (c *Conn) maxAuthRounds() int {
+	if c.MaxAuthRounds > 0 {
+		return c.MaxAuthRounds
+	}
+	return defaultMaxAuthRounds
+}
+
+// proxyAuthorization returns the credentials most recently produced by
+// Config.AuthHandler, if any, for use on the next request.
+func (c *Conn) proxyAuthorization() string {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+	return c.proxyAuth
+}
+
+// setProxyAuthorization records credentials produced by Config.AuthHandler
+// for doRequestOnce to present on the next request.
+func (c *Conn) setProxyAuthorization(credentials string) {
+	c.authMutex.Lock()
+	c.proxyAuth = credentials
+	c.authMutex.Unlock()
+}
@@ -0,0 +1,103 @@
+package enproxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// multiRoundAuthProxy simulates a proxy running a two-round challenge
+// scheme: it 407s with "round1" until it sees credentials "resp:round1",
+// then 407s with "round2" until it sees "resp:round2", after which it
+// answers 200.
+func multiRoundAuthProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	round := 1
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+
+		creds := req.Header.Get("Proxy-Authorization")
+		switch {
+		case round == 1 && creds != "resp:round1":
+			_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: round1\r\nContent-Length: 0\r\n\r\n"))
+			round = 2
+		case round == 2 && creds != "resp:round2":
+			_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: round2\r\nContent-Length: 0\r\n\r\n"))
+			round = 3
+		default:
+			_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+			return
+		}
+	}
+}
+
+func TestAuthHandlerCompletesMultiRoundChallenge(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go multiRoundAuthProxy(server)
+		return client, nil
+	}
+	var challenges []string
+	cfg.AuthHandler = func(challenge string) (string, error) {
+		challenges = append(challenges, challenge)
+		return fmt.Sprintf("resp:%s", challenge), nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"round1", "round2"}, challenges)
+}
+
+// alwaysAuthProxy 407s every request forever.
+func alwaysAuthProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: again\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestAuthHandlerGivesUpAfterMaxRounds(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go alwaysAuthProxy(server)
+		return client, nil
+	}
+	cfg.MaxAuthRounds = 2
+	cfg.AuthHandler = func(challenge string) (string, error) {
+		return "never good enough", nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opConnect, nil)
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	authFailed, ok := err.(*AuthFailed)
+	if !assert.True(t, ok, "expected *AuthFailed, got %T", err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 2, authFailed.Rounds)
+}
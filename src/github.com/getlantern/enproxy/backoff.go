@@ -0,0 +1,141 @@
+package enproxy
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff computes how long to wait between retry attempts. NextDelay is
+// called with the 1-based attempt number for the current retry sequence.
+// Reset clears any accumulated state so the next sequence starts cold;
+// it's called once per doRequest call before any retries begin.
+type Backoff interface {
+	NextDelay(attempt int) time.Duration
+	Reset()
+}
+
+// defaultBackoff is used when Config.Backoff is nil. It's stateless, so
+// it's safe to share across every Conn that falls back to it.
+var defaultBackoff Backoff = &ExponentialBackoff{}
+
+// backoffStrategy returns c.Backoff, or defaultBackoff if unset.
+func (c *Conn) backoffStrategy() Backoff {
+	if c.Backoff != nil {
+		return c.Backoff
+	}
+	return defaultBackoff
+}
+
+const (
+	defaultBackoffBase = 100 * time.Millisecond
+	defaultBackoffMax  = 30 * time.Second
+)
+
+// ExponentialBackoff doubles from Base on each attempt, capped at Max, and
+// returns a uniformly random delay between half of that and that, so
+// retries from many clients don't all land at once. It's stateless.
+type ExponentialBackoff struct {
+	// Base is the delay before jitter on the first attempt. Zero uses a
+	// 100ms default.
+	Base time.Duration
+	// Max caps the delay before jitter. Zero uses a 30s default.
+	Max time.Duration
+}
+
+func (b *ExponentialBackoff) NextDelay(attempt int) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	max := b.Max
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := max
+	// Guard against overflowing the shift for a large attempt count;
+	// anything that would have exceeded max gets capped to it anyway.
+	if attempt <= 62 {
+		if scaled := base * time.Duration(int64(1)<<uint(attempt-1)); scaled > 0 && scaled < max {
+			delay = scaled
+		}
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (b *ExponentialBackoff) Reset() {}
+
+// ConstantBackoff returns a delay between half of Delay and Delay itself,
+// jittered uniformly, for every attempt. It's stateless.
+type ConstantBackoff struct {
+	// Delay is the base delay before jitter. Zero uses a 1s default.
+	Delay time.Duration
+}
+
+func (b *ConstantBackoff) NextDelay(attempt int) time.Duration {
+	delay := b.Delay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (b *ConstantBackoff) Reset() {}
+
+// DecorrelatedJitterBackoff implements the "decorrelated jitter" strategy
+// (see the AWS Architecture Blog's backoff survey): each delay is drawn
+// uniformly from [Base, previous delay * 3], capped at Max, which spreads
+// out retries from synchronized clients better than a plain exponential
+// backoff does. Safe for concurrent use.
+type DecorrelatedJitterBackoff struct {
+	// Base is both the floor for every delay and the first attempt's
+	// delay. Zero uses a 100ms default.
+	Base time.Duration
+	// Max caps the delay. Zero uses a 30s default.
+	Max time.Duration
+
+	mu   sync.Mutex
+	prev time.Duration
+}
+
+func (b *DecorrelatedJitterBackoff) NextDelay(attempt int) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	max := b.Max
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prev := b.prev
+	if prev < base {
+		prev = base
+	}
+	upper := prev * 3
+	if upper > max {
+		upper = max
+	}
+	if upper <= base {
+		b.prev = base
+		return base
+	}
+
+	delay := base + time.Duration(rand.Int63n(int64(upper-base)))
+	b.prev = delay
+	return delay
+}
+
+func (b *DecorrelatedJitterBackoff) Reset() {
+	b.mu.Lock()
+	b.prev = 0
+	b.mu.Unlock()
+}
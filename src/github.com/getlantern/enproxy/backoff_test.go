@@ -0,0 +1,78 @@
+package enproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoffBounds(t *testing.T) {
+	b := &ExponentialBackoff{Base: 10 * time.Millisecond, Max: time.Second}
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay := b.NextDelay(attempt)
+		assert.True(t, delay >= 0, "delay should never be negative")
+		assert.True(t, delay <= b.Max, "delay should never exceed Max")
+	}
+	// A huge attempt count shouldn't overflow into a negative or tiny delay.
+	assert.True(t, b.NextDelay(1000) <= b.Max)
+}
+
+func TestExponentialBackoffGrows(t *testing.T) {
+	b := &ExponentialBackoff{Base: 10 * time.Millisecond, Max: time.Hour}
+	// NextDelay(n) draws from [scaled/2, scaled], so successive attempts'
+	// upper bounds should grow even though individual draws are random.
+	assert.True(t, b.NextDelay(1) <= 10*time.Millisecond)
+	assert.True(t, b.NextDelay(5) <= 160*time.Millisecond)
+}
+
+func TestExponentialBackoffDefaults(t *testing.T) {
+	b := &ExponentialBackoff{}
+	delay := b.NextDelay(1)
+	assert.True(t, delay >= 0 && delay <= defaultBackoffMax)
+}
+
+func TestConstantBackoffBounds(t *testing.T) {
+	b := &ConstantBackoff{Delay: 200 * time.Millisecond}
+	for attempt := 1; attempt <= 5; attempt++ {
+		delay := b.NextDelay(attempt)
+		assert.True(t, delay >= 0 && delay <= b.Delay, "delay %s should be within [0, %s]", delay, b.Delay)
+	}
+}
+
+func TestDecorrelatedJitterBackoffBounds(t *testing.T) {
+	b := &DecorrelatedJitterBackoff{Base: 10 * time.Millisecond, Max: time.Second}
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 20; attempt++ {
+		delay := b.NextDelay(attempt)
+		assert.True(t, delay >= b.Base, "delay should never be below Base")
+		assert.True(t, delay <= b.Max, "delay should never exceed Max")
+		// Each delay is drawn from [Base, 3*previous], so it can't jump by
+		// more than a factor of 3 between consecutive attempts.
+		if prev > 0 {
+			assert.True(t, delay <= 3*prev || delay == b.Max)
+		}
+		prev = delay
+	}
+}
+
+func TestDecorrelatedJitterBackoffReset(t *testing.T) {
+	b := &DecorrelatedJitterBackoff{Base: 10 * time.Millisecond, Max: time.Minute}
+	for i := 0; i < 10; i++ {
+		b.NextDelay(i + 1)
+	}
+	assert.True(t, b.prev > b.Base, "expected state to have grown past Base after several attempts")
+
+	b.Reset()
+	assert.Equal(t, time.Duration(0), b.prev)
+
+	// Right after Reset, the first delay should again be bounded by Base's
+	// neighborhood rather than whatever it grew to before.
+	delay := b.NextDelay(1)
+	assert.True(t, delay >= b.Base && delay <= 3*b.Base)
+}
+
+func TestDefaultBackoffIsExponential(t *testing.T) {
+	_, ok := defaultBackoff.(*ExponentialBackoff)
+	assert.True(t, ok, "default backoff should be exponential-with-jitter")
+}
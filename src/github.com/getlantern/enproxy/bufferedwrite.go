@@ -0,0 +1,24 @@
+package enproxy
+
+import "time"
+
+// BufferedWriteBytes returns how many bytes Write has accepted from the
+// app but not yet sent to the proxy, awaiting flush. As noted on
+// Config.WriteCoalesceWindow, Write currently issues a request for every
+// call immediately, so nothing is ever buffered and this is always 0; it's
+// here so that if this package grows a write-coalescing strategy, apps
+// already watching buffering behavior (for example alongside bytes
+// buffered on the read side) don't need a new method to observe it too.
+// It's a cheap, thread-safe read of the buffering strategy's state.
+func (c *Conn) BufferedWriteBytes() int {
+	return 0
+}
+
+// writeCoalesceWindow returns c.WriteCoalesceWindow, falling back to the
+// older Config.MaxBufferAge for callers that haven't migrated yet.
+func (c *Conn) writeCoalesceWindow() time.Duration {
+	if c.WriteCoalesceWindow > 0 {
+		return c.WriteCoalesceWindow
+	}
+	return c.MaxBufferAge
+}
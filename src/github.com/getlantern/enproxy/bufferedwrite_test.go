@@ -0,0 +1,24 @@
+package enproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferedWriteBytesIsAlwaysZeroTodaySinceWritesAreNeverBuffered(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.Equal(t, 0, conn.BufferedWriteBytes())
+
+	_, err := conn.Write([]byte("hello"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 0, conn.BufferedWriteBytes())
+}
@@ -0,0 +1,69 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// singleByteCapturingProxy answers the connect op, then records the body of
+// the first write op it sees along with when it arrived.
+func singleByteCapturingProxy(conn net.Conn, bodyCh chan<- []byte) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	bodyCh <- body
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+}
+
+// TestSingleByteWriteArrivesWithinMaxBufferAge documents that Write sends
+// every call as its own request immediately, so a single byte is always
+// observed by the proxy well within MaxBufferAge, with no buffering
+// strategy (and so no flush-trigger edge case) involved at all.
+func TestSingleByteWriteArrivesWithinMaxBufferAge(t *testing.T) {
+	bodyCh := make(chan []byte, 1)
+	cfg := testConfig()
+	cfg.MaxBufferAge = 50 * time.Millisecond
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go singleByteCapturingProxy(server, bodyCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	start := time.Now()
+	n, err := conn.Write([]byte{'x'})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, n)
+
+	select {
+	case body := <-bodyCh:
+		assert.Equal(t, []byte{'x'}, body)
+		assert.True(t, time.Since(start) < cfg.MaxBufferAge, "byte should have been sent well within MaxBufferAge")
+	case <-time.After(time.Second):
+		t.Fatal("proxy never saw the write")
+	}
+}
@@ -0,0 +1,61 @@
+package enproxy
+
+import (
+	"fmt"
+	"net"
+)
+
+// SetReadBuffer sets the SO_RCVBUF size on the underlying proxy TCP
+// socket, mirroring *net.TCPConn.SetReadBuffer. It applies immediately to
+// the current connection, if one is already dialed, and is reapplied to
+// every connection dialed after a redial. Returns an error if the current
+// connection isn't a TCP socket (for example net.Pipe in tests).
+func (c *Conn) SetReadBuffer(bytes int) error {
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	c.readBufferSize = &bytes
+	return c.applyBufferSizeLocked(bytes, (*net.TCPConn).SetReadBuffer)
+}
+
+// SetWriteBuffer is SetReadBuffer's SO_SNDBUF counterpart.
+func (c *Conn) SetWriteBuffer(bytes int) error {
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	c.writeBufferSize = &bytes
+	return c.applyBufferSizeLocked(bytes, (*net.TCPConn).SetWriteBuffer)
+}
+
+// applyBufferSizeLocked applies set to the current proxy connection, if
+// any. c.connInfoMutex must already be held.
+func (c *Conn) applyBufferSizeLocked(bytes int, set func(*net.TCPConn, int) error) error {
+	if c.ci == nil {
+		return nil
+	}
+	tcpConn, ok := c.ci.conn.(*net.TCPConn)
+	if !ok {
+		return fmt.Errorf("underlying proxy connection is not a TCP socket")
+	}
+	return set(tcpConn, bytes)
+}
+
+// maybeApplyBufferSizes reapplies any buffer sizes previously set via
+// SetReadBuffer/SetWriteBuffer to a freshly dialed proxy connection, so
+// they survive a redial. It's a no-op for non-TCP connections and when
+// neither has been set.
+func (c *Conn) maybeApplyBufferSizes(conn net.Conn) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if c.readBufferSize != nil {
+		if err := tcpConn.SetReadBuffer(*c.readBufferSize); err != nil {
+			return fmt.Errorf("Unable to set read buffer size: %v", err)
+		}
+	}
+	if c.writeBufferSize != nil {
+		if err := tcpConn.SetWriteBuffer(*c.writeBufferSize); err != nil {
+			return fmt.Errorf("Unable to set write buffer size: %v", err)
+		}
+	}
+	return nil
+}
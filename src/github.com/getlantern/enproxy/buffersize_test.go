@@ -0,0 +1,91 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetReadBufferErrorsForNonTCP(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	client, _ := net.Pipe()
+	defer func() { _ = client.Close() }()
+	conn.ci = &connInfo{conn: client}
+
+	assert.Error(t, conn.SetReadBuffer(64*1024))
+	assert.Error(t, conn.SetWriteBuffer(64*1024))
+}
+
+func TestSetReadBufferAppliesToTCPConn(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = ln.Close() }()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = client.Close() }()
+	<-accepted
+
+	conn.ci = &connInfo{conn: client}
+	assert.NoError(t, conn.SetReadBuffer(64*1024))
+	assert.NoError(t, conn.SetWriteBuffer(64*1024))
+}
+
+func TestSetReadBufferSurvivesRedial(t *testing.T) {
+	dialCount := 0
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialCount++
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = ln.Close() }()
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			c, _ := ln.Accept()
+			accepted <- c
+		}()
+		client, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+		go serveOneThenClose(<-accepted)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	// Set after the first dial; it should still apply once the next request
+	// below redials a fresh connection.
+	assert.NoError(t, conn.SetReadBuffer(32*1024))
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 2, dialCount, "Connection: close on the first response should have forced a redial")
+}
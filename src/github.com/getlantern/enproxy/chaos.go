@@ -0,0 +1,90 @@
+package enproxy
+
+import (
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ChaosConfig injects artificial latency, dropped requests and corrupted
+// responses into the request path, so a caller can deterministically
+// exercise its own error handling and retry logic over the tunnel. It has
+// no effect unless set on Config.Chaos; that field is nil by default, and
+// this type's name and doc comments are both deliberately explicit so it's
+// never enabled by accident.
+type ChaosConfig struct {
+	// Latency is slept before every request is sent to the proxy.
+	Latency time.Duration
+
+	// DropFraction is the probability, in [0, 1], that a request is
+	// dropped instead of sent, simulating a connection that died in
+	// flight. Zero (the default) never drops.
+	DropFraction float64
+
+	// CorruptFraction is the probability, in [0, 1], that an otherwise
+	// successful response's body is corrupted before the caller sees it.
+	// Zero (the default) never corrupts.
+	CorruptFraction float64
+
+	// Seed seeds the randomness behind DropFraction and CorruptFraction,
+	// so a run using the same seed drops and corrupts the same requests
+	// every time. The point of this option is reproducible test runs, not
+	// unpredictability, so zero uses a fixed seed rather than real
+	// entropy.
+	Seed int64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// chaosDropped is returned by doRequestOnce when Config.Chaos drops a
+// request instead of sending it.
+type chaosDropped struct{}
+
+func (e *chaosDropped) Error() string {
+	return "request dropped by Config.Chaos"
+}
+
+// applyLatency sleeps for cc.Latency, if any.
+func (cc *ChaosConfig) applyLatency() {
+	if cc.Latency > 0 {
+		time.Sleep(cc.Latency)
+	}
+}
+
+func (cc *ChaosConfig) randFloat() float64 {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.rnd == nil {
+		cc.rnd = rand.New(rand.NewSource(cc.Seed))
+	}
+	return cc.rnd.Float64()
+}
+
+// shouldDrop reports whether the request currently being sent should be
+// dropped, consuming one random draw if DropFraction is set.
+func (cc *ChaosConfig) shouldDrop() bool {
+	return cc.DropFraction > 0 && cc.randFloat() < cc.DropFraction
+}
+
+// shouldCorrupt reports whether the response currently being read should
+// be corrupted, consuming one random draw if CorruptFraction is set.
+func (cc *ChaosConfig) shouldCorrupt() bool {
+	return cc.CorruptFraction > 0 && cc.randFloat() < cc.CorruptFraction
+}
+
+// corruptingReader flips every bit it reads, turning valid response bytes
+// into garbage without changing their length or the read's error/EOF
+// behavior.
+type corruptingReader struct {
+	io.Reader
+}
+
+func (r *corruptingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] ^= 0xFF
+	}
+	return n, err
+}
@@ -0,0 +1,98 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// payloadProxy answers every request with a fixed, non-empty body, so
+// chaos corruption has bytes to actually flip.
+func payloadProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello"))
+	}
+}
+
+func TestChaosDropFractionOneAlwaysDropsWithoutDialing(t *testing.T) {
+	dialed := false
+	cfg := testConfig()
+	cfg.Chaos = &ChaosConfig{DropFraction: 1, Seed: 1}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialed = true
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opConnect, nil)
+
+	assert.Error(t, err)
+	assert.False(t, dialed, "a dropped request should never reach the proxy")
+}
+
+func TestChaosDropFractionZeroNeverDrops(t *testing.T) {
+	cfg := testConfig()
+	cfg.Chaos = &ChaosConfig{DropFraction: 0, Seed: 1}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+}
+
+func TestChaosCorruptFractionOneCorruptsResponseBody(t *testing.T) {
+	cfg := testConfig()
+	cfg.Chaos = &ChaosConfig{CorruptFraction: 1, Seed: 1}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go payloadProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NotEqual(t, "hello", string(body), "corrupted body should no longer match what the proxy sent")
+	assert.Len(t, body, len("hello"), "corruption should not change the body's length")
+}
+
+func TestChaosSeedIsReproducible(t *testing.T) {
+	outcomes := func() []bool {
+		cfg := testConfig()
+		cfg.Chaos = &ChaosConfig{DropFraction: 0.5, Seed: 42}
+		var results []bool
+		for i := 0; i < 5; i++ {
+			conn := cfg.NewConn("dest.example.com:80")
+			_, err := conn.doRequest(opConnect, nil)
+			results = append(results, err == nil)
+		}
+		return results
+	}
+
+	first := outcomes()
+	second := outcomes()
+	assert.Equal(t, first, second, "the same seed should drop the same sequence of requests")
+}
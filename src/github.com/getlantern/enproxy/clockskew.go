@@ -0,0 +1,38 @@
+package enproxy
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// XEnproxyServerTime carries the proxy's own clock, as of generating the
+// response, on a connect response, so the client can measure clock skew.
+// Useful for diagnosing time-based auth (HMAC, TOTP) failures caused by
+// drift between client and proxy.
+const XEnproxyServerTime = "X-Enproxy-Server-Time"
+
+// recordClockSkew parses a connect response's server-time header, if
+// present, and caches the difference from our own clock for later
+// retrieval via ServerClockSkew. A missing or unparseable header leaves
+// the previously recorded skew (zero, if there's never been one) in place.
+func (c *Conn) recordClockSkew(header headerGetter) {
+	raw := header.Get(XEnproxyServerTime)
+	if raw == "" {
+		return
+	}
+	serverTime, err := time.Parse(http.TimeFormat, raw)
+	if err != nil {
+		log.Debugf("Unable to parse server time from proxy: %v", err)
+		return
+	}
+	atomic.StoreInt64(&c.clockSkew, int64(serverTime.Sub(time.Now())))
+}
+
+// ServerClockSkew returns how far ahead (positive) or behind (negative) of
+// our own clock the proxy's clock was, as of the last connect response
+// that included XEnproxyServerTime. It's zero if the proxy has never
+// reported one.
+func (c *Conn) ServerClockSkew() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.clockSkew))
+}
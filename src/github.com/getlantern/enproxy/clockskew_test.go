@@ -0,0 +1,51 @@
+package enproxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clockSkewedProxy answers a connect request reporting its own clock as
+// skew ahead of the real time.
+func clockSkewedProxy(conn net.Conn, skew time.Duration) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	serverTime := time.Now().Add(skew).UTC().Format(http.TimeFormat)
+	_, _ = conn.Write([]byte(fmt.Sprintf(
+		"HTTP/1.1 200 OK\r\n%s: %s\r\nContent-Length: 0\r\n\r\n", XEnproxyServerTime, serverTime)))
+}
+
+func TestServerClockSkew(t *testing.T) {
+	skew := 90 * time.Second
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go clockSkewedProxy(server, skew)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	assert.Equal(t, time.Duration(0), conn.ServerClockSkew(), "should be zero before any connect response is seen")
+
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	// http.TimeFormat only has second precision, so allow a little slack.
+	assert.InDelta(t, skew.Seconds(), conn.ServerClockSkew().Seconds(), 1)
+}
@@ -0,0 +1,13 @@
+package enproxy
+
+// ClosedWhileConnecting is returned by Connect when Close is called
+// concurrently with it -- for example an app that cancels a dial in
+// progress -- rather than Connect either succeeding (leaving the proxy
+// believing it has a tunnel nobody will ever use or close) or racily
+// returning whatever dial/read error closing the half-established
+// connection happened to produce.
+type ClosedWhileConnecting struct{}
+
+func (e *ClosedWhileConnecting) Error() string {
+	return "Close called while Connect was still establishing the tunnel"
+}
@@ -0,0 +1,86 @@
+package enproxy
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingUntilClosedProxy answers nothing until release is closed, then
+// answers every request it eventually reads with 200 OK, simulating a
+// connect op that's still in flight when the app gives up on it.
+func blockingUntilClosedProxy(conn net.Conn, release <-chan struct{}) {
+	<-release
+	serveFakeProxy(conn)
+}
+
+// firstDialBlocksUntilClosed returns a DialProxy func whose first dial is
+// held open by blockingUntilClosedProxy until release is closed; every dial
+// after that (for example the explicit close op Close issues against a
+// freshly redialed connection) is answered immediately by serveFakeProxy, so
+// only the in-progress Connect call is actually blocked. claimed is accessed
+// atomically since Connect's dial and Close's redial-for-close-op can race
+// to dial concurrently.
+func firstDialBlocksUntilClosed(release <-chan struct{}) func(addr string) (net.Conn, error) {
+	var claimed int32
+	return func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		if atomic.CompareAndSwapInt32(&claimed, 0, 1) {
+			go blockingUntilClosedProxy(server, release)
+		} else {
+			go serveFakeProxy(server)
+		}
+		return client, nil
+	}
+}
+
+// TestConnectReturnsClosedWhileConnectingOnRaceWithClose confirms that
+// calling Close concurrently with an in-progress Connect -- for example an
+// app canceling a dial early -- deterministically fails Connect with
+// *ClosedWhileConnecting instead of leaving the outcome to whatever error
+// (or lack of one) closing the half-established connection happened to
+// produce.
+func TestConnectReturnsClosedWhileConnectingOnRaceWithClose(t *testing.T) {
+	release := make(chan struct{})
+	cfg := testConfig()
+	cfg.DialProxy = firstDialBlocksUntilClosed(release)
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connectErrCh := make(chan error, 1)
+	go func() { connectErrCh <- conn.Connect() }()
+
+	// Give Connect a moment to block inside its connect op before closing,
+	// so this actually exercises the race rather than closing before
+	// Connect even starts.
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, conn.Close())
+	close(release)
+
+	select {
+	case err := <-connectErrCh:
+		_, ok := err.(*ClosedWhileConnecting)
+		assert.True(t, ok, "expected *ClosedWhileConnecting, got %T (%v)", err, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connect never returned after Close raced in")
+	}
+
+	assert.Equal(t, 0, conn.GoroutineCount(), "no background goroutines should be left running")
+}
+
+// TestConnectReturnsClosedWhileConnectingWhenAlreadyClosed confirms the
+// simpler case -- Close wins the race entirely, completing before Connect
+// is even called -- is also handled deterministically rather than Connect
+// going ahead and establishing a tunnel nobody will ever use or close.
+func TestConnectReturnsClosedWhileConnectingWhenAlreadyClosed(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	assert.NoError(t, conn.Close())
+
+	err := conn.Connect()
+	_, ok := err.(*ClosedWhileConnecting)
+	assert.True(t, ok, "expected *ClosedWhileConnecting, got %T (%v)", err, err)
+}
@@ -0,0 +1,58 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWriteCoalesceWindowFallsBackToMaxBufferAge confirms the renamed
+// Config.WriteCoalesceWindow takes precedence when set, but old callers
+// that only set Config.MaxBufferAge still get its value.
+func TestWriteCoalesceWindowFallsBackToMaxBufferAge(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxBufferAge = 10 * time.Millisecond
+	conn := cfg.NewConn("dest.example.com:80")
+	assert.Equal(t, 10*time.Millisecond, conn.writeCoalesceWindow())
+
+	conn.WriteCoalesceWindow = 25 * time.Millisecond
+	assert.Equal(t, 25*time.Millisecond, conn.writeCoalesceWindow())
+}
+
+// TestSingleByteWriteArrivesWithinWriteCoalesceWindow is
+// TestSingleByteWriteArrivesWithinMaxBufferAge's counterpart for the new
+// field name: since Write still issues a request per call immediately,
+// setting WriteCoalesceWindow (rather than the legacy MaxBufferAge) is
+// likewise trivially satisfied.
+func TestSingleByteWriteArrivesWithinWriteCoalesceWindow(t *testing.T) {
+	bodyCh := make(chan []byte, 1)
+	cfg := testConfig()
+	cfg.WriteCoalesceWindow = 50 * time.Millisecond
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go singleByteCapturingProxy(server, bodyCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	start := time.Now()
+	n, err := conn.Write([]byte{'x'})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, n)
+
+	select {
+	case body := <-bodyCh:
+		assert.Equal(t, []byte{'x'}, body)
+		assert.True(t, time.Since(start) < cfg.WriteCoalesceWindow, "byte should have been sent well within WriteCoalesceWindow")
+	case <-time.After(time.Second):
+		t.Fatal("proxy never saw the write")
+	}
+}
@@ -0,0 +1,39 @@
+package enproxy
+
+// compactIDHeader, compactDestAddrHeader and compactOpHeader are the short
+// forms of XEnproxyID, XEnproxyDestAddr and XEnproxyOp sent instead of the
+// full names when Config.CompactHeaders is set. Unlike CompactFraming,
+// which replaces the whole request with a binary frame, this keeps ordinary
+// HTTP framing (for proxies that need to stay on it, e.g. to terminate TLS
+// or apply HTTP-aware middleware) and just trims the header overhead that
+// can push a request over a constrained path's effective MTU.
+const (
+	compactIDHeader       = "X-Ei"
+	compactDestAddrHeader = "X-Ed"
+	compactOpHeader       = "X-Eo"
+)
+
+// setIdentityHeaders sets the per-request headers the proxy needs to route
+// req to the right tunnel, destination and op, in either their full or
+// compact form depending on Config.CompactHeaders. Compact form also omits
+// User-Agent and XEnproxyReqID, since neither is needed for the proxy to
+// handle the request -- they only exist for logging and tracing, which
+// aren't worth the extra bytes on a path where headers are already the
+// problem.
+func (c *Conn) setIdentityHeaders(req headerSetter, op string, reqID string) {
+	if c.CompactHeaders {
+		// An empty User-Agent value, as opposed to an absent one, tells
+		// net/http not to fall back to its own default "Go-http-client/..."
+		// value, which would erase the savings from dropping the header.
+		req.Set("User-Agent", "")
+		req.Set(compactIDHeader, c.id)
+		req.Set(compactDestAddrHeader, c.addr)
+		req.Set(compactOpHeader, op)
+		return
+	}
+	req.Set("User-Agent", c.userAgent())
+	req.Set(XEnproxyID, c.id)
+	req.Set(XEnproxyReqID, reqID)
+	req.Set(XEnproxyDestAddr, c.addr)
+	req.Set(XEnproxyOp, op)
+}
@@ -0,0 +1,81 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// headerCapturingProxy answers a single request with 200 OK, reporting the
+// request's headers to seenCh for the test to inspect.
+func headerCapturingProxy(conn net.Conn, seenCh chan<- http.Header) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	seenCh <- req.Header
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+}
+
+// TestCompactHeadersUsesShortFormsAndDropsOptionalOnes confirms
+// Config.CompactHeaders swaps in the short identity headers and omits
+// User-Agent and XEnproxyReqID.
+func TestCompactHeadersUsesShortFormsAndDropsOptionalOnes(t *testing.T) {
+	seenCh := make(chan http.Header, 1)
+	cfg := testConfig()
+	cfg.CompactHeaders = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go headerCapturingProxy(server, seenCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	header := <-seenCh
+	assert.Equal(t, conn.id, header.Get(compactIDHeader))
+	assert.Equal(t, "dest.example.com:80", header.Get(compactDestAddrHeader))
+	assert.Equal(t, opConnect, header.Get(compactOpHeader))
+	assert.Empty(t, header.Get(XEnproxyID))
+	assert.Empty(t, header.Get(XEnproxyDestAddr))
+	assert.Empty(t, header.Get(XEnproxyOp))
+	assert.Empty(t, header.Get(XEnproxyReqID))
+	assert.Empty(t, header.Get("User-Agent"))
+}
+
+// TestCompactHeadersOffUsesFullForms confirms the default behavior is
+// unchanged when CompactHeaders isn't set.
+func TestCompactHeadersOffUsesFullForms(t *testing.T) {
+	seenCh := make(chan http.Header, 1)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go headerCapturingProxy(server, seenCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	header := <-seenCh
+	assert.Equal(t, conn.id, header.Get(XEnproxyID))
+	assert.Equal(t, "dest.example.com:80", header.Get(XEnproxyDestAddr))
+	assert.Equal(t, opConnect, header.Get(XEnproxyOp))
+	assert.Empty(t, header.Get(compactIDHeader))
+}
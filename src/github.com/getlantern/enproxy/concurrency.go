@@ -0,0 +1,39 @@
+package enproxy
+
+// semaphore bounds how many callers may hold it at once. A nil semaphore
+// (the zero value) never blocks, for the common case of no configured
+// limit.
+type semaphore chan struct{}
+
+// newSemaphore returns a semaphore allowing up to n concurrent holders, or
+// a nil (unlimited) semaphore if n is zero or negative.
+func newSemaphore(n int) semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+func (s semaphore) acquire() {
+	if s == nil {
+		return
+	}
+	s <- struct{}{}
+}
+
+func (s semaphore) release() {
+	if s == nil {
+		return
+	}
+	<-s
+}
+
+// initConcurrencyLimits lazily constructs the read and write semaphores
+// from Config.MaxConcurrentReads/MaxConcurrentWrites. Zero (the default)
+// means unlimited, preserving the existing serial behavior.
+func (c *Conn) initConcurrencyLimits() {
+	c.concurrencyOnce.Do(func() {
+		c.readSem = newSemaphore(c.MaxConcurrentReads)
+		c.writeSem = newSemaphore(c.MaxConcurrentWrites)
+	})
+}
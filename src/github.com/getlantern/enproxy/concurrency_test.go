@@ -0,0 +1,176 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowThenFastWriteProxy answers a connect op normally, then holds the
+// first write op open until release is closed before answering it and any
+// further write ops immediately.
+func slowThenFastWriteProxy(conn net.Conn, release <-chan struct{}) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	<-release
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	for {
+		req, err = http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestMaxConcurrentWritesSerializesWrites(t *testing.T) {
+	release := make(chan struct{})
+	cfg := testConfig()
+	cfg.MaxConcurrentWrites = 1
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go slowThenFastWriteProxy(server, release)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("first"))
+		firstDone <- err
+	}()
+
+	for conn.PendingRequests() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	secondStarted := make(chan struct{})
+	secondDone := make(chan error, 1)
+	go func() {
+		close(secondStarted)
+		_, err := conn.Write([]byte("second"))
+		secondDone <- err
+	}()
+	<-secondStarted
+
+	select {
+	case <-secondDone:
+		t.Fatal("second write shouldn't complete while the semaphore-held first write is still in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-firstDone)
+	assert.NoError(t, <-secondDone)
+}
+
+// slowReadThenFastWriteProxy answers a connect op normally, then reads the
+// next request (the read op), closes started, and holds that request's
+// response back until release is closed, answering it and any request after
+// it immediately once released. It reads nothing further off conn while the
+// response is held back, so a concurrent op's request can't complete its
+// exchange until release is closed.
+func slowReadThenFastWriteProxy(conn net.Conn, started chan<- struct{}, release <-chan struct{}) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	close(started)
+	<-release
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	for {
+		req, err = http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+// TestConcurrentReadAndWriteSerializeRequestIO confirms a Write blocks until
+// a concurrently in-flight opRead's request/response exchange has completed,
+// not just until the proxy connection has been obtained: the write must not
+// complete while the proxy is still holding back the read's response, and
+// must complete once that response is released.
+func TestConcurrentReadAndWriteSerializeRequestIO(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go slowReadThenFastWriteProxy(server, started, release)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	readDone := make(chan error, 1)
+	go func() {
+		resp, err := conn.doRequest(opRead, nil)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		readDone <- err
+	}()
+	<-started
+
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("payload"))
+		writeDone <- err
+	}()
+
+	select {
+	case <-writeDone:
+		t.Fatal("write shouldn't complete while the in-flight read's request/response exchange is still pending")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-readDone)
+	assert.NoError(t, <-writeDone)
+}
@@ -0,0 +1,119 @@
+package enproxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bigChunkThenStallProxy answers connect normally, then answers exactly one
+// read op with the entirety of payload, and stalls (without responding) on
+// any read op after that, so a test can drain payload without the
+// background processReads loop fetching anything further.
+func bigChunkThenStallProxy(conn net.Conn, payload []byte) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	header := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", len(payload))
+	_, _ = conn.Write([]byte(header))
+	_, _ = conn.Write(payload)
+
+	_, _ = http.ReadRequest(reader)
+}
+
+// TestConcurrentReadsDoNotCorruptOrDuplicateData stress-tests many
+// goroutines calling Read on the same Conn at once. The payload is a
+// sequence of ascending uint32 records, so any race in readBuf/peekErr
+// handling would surface as a read returning a non-consecutive record run,
+// a record delivered more than once, or a record missing entirely.
+func TestConcurrentReadsDoNotCorruptOrDuplicateData(t *testing.T) {
+	const numRecords = 1000
+	const recordsPerRead = 10
+	const readSize = recordsPerRead * 4
+
+	payload := make([]byte, numRecords*4)
+	for i := 0; i < numRecords; i++ {
+		binary.BigEndian.PutUint32(payload[i*4:], uint32(i))
+	}
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go bigChunkThenStallProxy(server, payload)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	var claimed int64
+	var seenMutex sync.Mutex
+	seen := make(map[uint32]bool)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if atomic.AddInt64(&claimed, readSize) > int64(len(payload)) {
+					return
+				}
+
+				buf := make([]byte, readSize)
+				n, err := conn.Read(buf)
+				if !assert.NoError(t, err) {
+					return
+				}
+				if !assert.Equal(t, readSize, n, "each claimed slot should read exactly one full chunk") {
+					return
+				}
+
+				var records [recordsPerRead]uint32
+				for i := 0; i < recordsPerRead; i++ {
+					records[i] = binary.BigEndian.Uint32(buf[i*4:])
+				}
+				for i := 1; i < recordsPerRead; i++ {
+					if !assert.Equal(t, records[i-1]+1, records[i], "records within a single Read should be consecutive, not interleaved from elsewhere") {
+						return
+					}
+				}
+
+				seenMutex.Lock()
+				for _, r := range records {
+					assert.False(t, seen[r], "record %d delivered more than once", r)
+					seen[r] = true
+				}
+				seenMutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	seenMutex.Lock()
+	defer seenMutex.Unlock()
+	assert.Equal(t, numRecords, len(seen), "every record should have been delivered exactly once")
+}
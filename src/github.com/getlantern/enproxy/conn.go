@@ -0,0 +1,1160 @@
+package enproxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.google.com/p/go-uuid/uuid"
+)
+
+// connInfo tracks a connection to the proxy along with bookkeeping about its
+// use, so that Conn can decide when it needs to redial.
+type connInfo struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// upgraded records whether the proxy acknowledged switching this
+	// connection to compact binary framing (see framing.go).
+	upgraded bool
+
+	// writeDict and readDict are the most recent bytes written/read over
+	// this connection, used as preset dictionaries so each frame's
+	// compression can benefit from the ones before it. See
+	// Config.StreamCompression.
+	writeDict []byte
+	readDict  []byte
+}
+
+// Conn implements net.Conn by tunneling reads and writes through a series of
+// HTTP requests to a proxy identified by Config.Host. Conns are created via
+// Config.NewConn.
+type Conn struct {
+	Config
+
+	id   string
+	addr string
+
+	// altAddrs are additional destination addresses offered to the proxy
+	// for failover, beyond addr. See DialMulti.
+	altAddrs []string
+	// chosenAddr is which of addr/altAddrs the proxy reported actually
+	// connecting to, once known. Guarded by connInfoMutex.
+	chosenAddr string
+	// resolvedDestAddr is the address the proxy reported actually
+	// connecting to on the wire (e.g. after its own DNS resolution), once
+	// known. Guarded by connInfoMutex.
+	resolvedDestAddr string
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+
+	connInfoMutex sync.Mutex
+	ci            *connInfo
+
+	// reqMutex serializes the actual request/response I/O doRequestOnce
+	// performs against the current proxy connection; see its doc comment.
+	reqMutex sync.Mutex
+
+	// readResponsesCh receives chunks of response body data as they arrive,
+	// allowing Read to return partial data without waiting for a full
+	// response to finish. It's fed by processReads.
+	readResponsesCh chan []byte
+	readErrCh       chan error
+	readBuf         []byte
+
+	// readMutex serializes Read and Peek against each other and against
+	// themselves, since net.Conn doesn't promise Read is safe to call
+	// concurrently from multiple goroutines and readBuf/peekErr below would
+	// otherwise race. Rather than leaving that undefined behavior in place
+	// or panicking on misuse, concurrent callers simply queue up and see
+	// the stream's bytes in the order Read happened to serialize them.
+	readMutex sync.Mutex
+
+	// peekErr stashes a read error or EOF that Peek consumed from
+	// readErrCh/closeCh while buffering ahead of n, so the next Read still
+	// observes it exactly once instead of blocking forever waiting on a
+	// channel nothing will ever send to again.
+	peekErr error
+
+	tlsConfigOnce   sync.Once
+	cachedTLSConfig *tls.Config
+
+	strategy string
+
+	limitersOnce sync.Once
+	readLimiter  *tokenBucket
+	writeLimiter *tokenBucket
+
+	diagnosticsMutex sync.Mutex
+	diagnostics      *Diagnostics
+
+	powerMode int32
+
+	destSlotHeld bool
+
+	connectedAt             time.Time
+	bytesRead, bytesWritten int64
+
+	// connected is 1 once Connect has completed successfully; see
+	// isConnected and NotConnected.
+	connected int32
+
+	pendingWrites int32
+	quiescing     int32
+
+	clockSkew int64
+
+	concurrencyOnce sync.Once
+	readSem         semaphore
+	writeSem        semaphore
+
+	// readBufferSize and writeBufferSize are the socket buffer sizes most
+	// recently requested via SetReadBuffer/SetWriteBuffer, reapplied to
+	// the proxy connection on every redial. Guarded by connInfoMutex.
+	readBufferSize  *int
+	writeBufferSize *int
+
+	// authMutex guards proxyAuth, the credentials most recently produced
+	// by Config.AuthHandler.
+	authMutex sync.Mutex
+	proxyAuth string
+
+	// userAgentIdx is the round-robin cursor into Config.UserAgents.
+	userAgentIdx uint64
+	// uaMutex guards currentUA, the User-Agent settled on by the most
+	// recent pickUserAgent call.
+	uaMutex   sync.Mutex
+	currentUA string
+
+	// activeGoroutines counts c's currently running background
+	// goroutines; see GoroutineCount.
+	activeGoroutines int32
+
+	// writeSeq is the most recently assigned XEnproxyWriteSeq value.
+	writeSeq uint64
+
+	// errorCounts tracks failures observed by doRequest, broken down by op
+	// and category; see ErrorCounts and Conn.stats.
+	errorCounts ErrorCounts
+
+	// writeQueueDelay is an exponential moving average, in nanoseconds, of
+	// how long a Write call has spent waiting on writeSem before its
+	// request is actually issued; see WriteQueueDelay.
+	writeQueueDelay int64
+
+	// lastActivityNanos is UnixNano of when the proxy connection was last
+	// used, stored atomically rather than under connInfoMutex since
+	// markActive fires on every read and write and a mutex there would be
+	// a contention point under high request rates. Conns are always
+	// heap-allocated (via Config.NewConn), so this field -- like the other
+	// atomically-accessed int64 fields above -- already gets the 64-bit
+	// alignment atomic ops need on 32-bit platforms for free.
+	lastActivityNanos int64
+
+	// forwardedHeadersMutex guards forwardedHeaders, populated per
+	// Config.ForwardResponseHeaders; see forwardheaders.go.
+	forwardedHeadersMutex sync.Mutex
+	forwardedHeaders      http.Header
+
+	// timingsMutex guards lastProxyDialDuration, lastTLSHandshakeDuration
+	// and establishmentTimings; see timings.go.
+	timingsMutex             sync.Mutex
+	lastProxyDialDuration    time.Duration
+	lastTLSHandshakeDuration time.Duration
+	establishmentTimings     Timings
+
+	// chunkedEncoding is set once Config.AutoNegotiateEncoding has seen
+	// the proxy reject a request's encoding, remembering the switch to
+	// chunked for the rest of c's requests; see encoding.go.
+	chunkedEncoding int32
+
+	// requestsStarted and redials count every doRequest call and every
+	// transport-retry fireOnRedial fires, respectively; see statslog.go.
+	requestsStarted int64
+	redials         int64
+}
+
+// initLimiters lazily constructs the read and write token buckets from the
+// effective configured rates, falling back to MaxBytesPerSecond for whichever
+// direction doesn't have its own override.
+func (c *Conn) initLimiters() {
+	c.limitersOnce.Do(func() {
+		readRate := c.MaxReadBytesPerSecond
+		if readRate == 0 {
+			readRate = c.MaxBytesPerSecond
+		}
+		writeRate := c.MaxWriteBytesPerSecond
+		if writeRate == 0 {
+			writeRate = c.MaxBytesPerSecond
+		}
+		c.readLimiter = newTokenBucket(readRate)
+		c.writeLimiter = newTokenBucket(writeRate)
+	})
+}
+
+// NewConn creates a new Conn that will tunnel to addr through the proxy
+// described by cfg. The returned Conn has not yet dialed the proxy; callers
+// must still call Connect.
+func (cfg Config) NewConn(addr string) *Conn {
+	return &Conn{
+		Config:          cfg,
+		id:              uuid.New(),
+		addr:            addr,
+		closeCh:         make(chan struct{}),
+		readResponsesCh: make(chan []byte),
+		readErrCh:       make(chan error, 1),
+		powerMode:       int32(cfg.PowerMode),
+	}
+}
+
+// Connect establishes the logical connection to Conn's destination by
+// issuing a connect op to the proxy, then starts the background goroutine
+// that polls for and streams back response data.
+func (c *Conn) Connect() error {
+	select {
+	case <-c.closeCh:
+		return &ClosedWhileConnecting{}
+	default:
+	}
+
+	if c.DestLimiter != nil {
+		if err := c.DestLimiter.acquire(c.addr, c.BlockOnDestLimit); err != nil {
+			return err
+		}
+		c.destSlotHeld = true
+	}
+
+	connectOpStart := time.Now()
+	resp, err := c.doRequest(opConnect, nil)
+	connectOpDuration := time.Since(connectOpStart)
+	if err != nil {
+		if c.destSlotHeld {
+			c.DestLimiter.release(c.addr)
+			c.destSlotHeld = false
+		}
+		select {
+		case <-c.closeCh:
+			// Close raced in while the connect op was in flight and won,
+			// forcibly closing the proxy connection out from under it (the
+			// same mechanism that unblocks a stuck processReads, see
+			// closeWithReason); report the deterministic reason rather than
+			// whatever dial/read error that force-close happened to produce.
+			return &ClosedWhileConnecting{}
+		default:
+		}
+		return fmt.Errorf("Unable to connect to %v via proxy: %v", c.addr, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	select {
+	case <-c.closeCh:
+		// Close raced in and won while the connect op was in flight, after
+		// it had already gone far enough that doRequest saw success rather
+		// than the broken-connection error that's the common case here.
+		// closeWithReason already ran its own close op against whatever
+		// connection existed at the time, which may well have been before
+		// the proxy considered us connected at all, so send one more now
+		// that it does, on a best-effort basis -- there's no one left to
+		// hand a second error to if this one fails too.
+		if c.destSlotHeld {
+			c.DestLimiter.release(c.addr)
+			c.destSlotHeld = false
+		}
+		_, _ = c.doRequest(opClose, nil)
+		return &ClosedWhileConnecting{}
+	default:
+	}
+
+	c.recordEstablishmentTimings(connectOpDuration)
+	c.connectedAt = time.Now()
+	c.markActive()
+	c.settleStrategy(resp)
+	if c.CompactFraming {
+		c.tryUpgradeToCompactFraming(resp)
+	}
+	if c.OptimisticReadWindow > 0 {
+		c.bufferOptimisticRead(resp)
+	}
+	if err := c.checkRequiredFeatures(); err != nil {
+		if c.destSlotHeld {
+			c.DestLimiter.release(c.addr)
+			c.destSlotHeld = false
+		}
+		c.closeProxyConn()
+		return err
+	}
+	if c.Metrics != nil {
+		c.Metrics.Connected(c.EstablishmentTimings())
+	}
+	c.goroutineStarted()
+	go c.processReads()
+	c.maybeStartStatsLog()
+	return nil
+}
+
+// tryUpgradeToCompactFraming asks the proxy, via the connect response
+// already in hand, whether it's willing to switch this connection to
+// compact binary framing. The proxy signals acceptance with a 101 Switching
+// Protocols status and the same compactUpgradeHeader we sent; anything else
+// means it doesn't support the upgrade, and we silently keep using HTTP
+// framing for this Conn.
+func (c *Conn) tryUpgradeToCompactFraming(resp *http.Response) {
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get(compactUpgradeHeader) == "" {
+		return
+	}
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	if c.ci != nil {
+		c.ci.upgraded = true
+	}
+}
+
+// bufferOptimisticRead pulls whatever destination data the proxy included in
+// the connect response (see Config.OptimisticReadWindow) into readBuf, so the
+// app's first Read is satisfied from it immediately instead of waiting on
+// processReads' first poll. A proxy that doesn't understand
+// XEnproxyOptimisticReadWindow just returns its ordinary empty connect body,
+// so this is a no-op against one. Whatever was read successfully is kept
+// even if reading the rest of the body then fails (for example a
+// server-speaks-first destination whose banner arrives intact just before
+// the proxy connection drops) -- the banner already in hand is still worth
+// delivering to the app's first Read, and the dropped connection surfaces
+// on its own through the next op's error instead.
+func (c *Conn) bufferOptimisticRead(resp *http.Response) {
+	prefetched, _ := io.ReadAll(io.LimitReader(resp.Body, int64(c.OptimisticReadWindow)))
+	if len(prefetched) == 0 {
+		return
+	}
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+	c.readBuf = append(c.readBuf, prefetched...)
+}
+
+// settleStrategy determines which request strategy the proxy negotiated for
+// this Conn (currently just strategyPolling, since that's the only strategy
+// this package implements) and reports it via Config.OnStrategyChange. It's
+// called once, after the connect response comes back, and again whenever a
+// strategy switch is implemented in the future.
+func (c *Conn) settleStrategy(resp *http.Response) {
+	strategy := resp.Header.Get(XEnproxyStrategy)
+	if strategy == "" {
+		strategy = strategyPolling
+	}
+	c.strategy = strategy
+	if c.OnStrategyChange != nil {
+		c.OnStrategyChange("", c.strategy)
+	}
+}
+
+// processReads repeatedly issues read ops to the proxy and streams the
+// response body to readResponsesCh as it arrives, chunk by chunk, rather
+// than waiting for each response to finish before delivering any of it.
+// This keeps latency-sensitive, incrementally-produced responses (for
+// example interactive shell output) from stalling in Conn's internals.
+//
+// A redial here (see doRequest) always starts the next read op from
+// wherever the proxy's own read cursor for this logical connection
+// happens to be; there's no offset handshake to race, and so nothing for
+// processReads to deduplicate, because this package doesn't implement a
+// resume feature (see ActiveFeatures.Resume) -- a redial reconnects to the
+// same destination tunnel, it doesn't resume a byte stream from a
+// declared offset.
+func (c *Conn) processReads() {
+	defer c.goroutineStopped()
+	buf := make([]byte, 4096)
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		default:
+		}
+		c.pollDelay()
+		select {
+		case <-c.closeCh:
+			return
+		default:
+		}
+
+		c.initConcurrencyLimits()
+		c.readSem.acquire()
+		resp, err := c.doRequest(opRead, nil)
+		c.readSem.release()
+		if err != nil {
+			if _, exhausted := err.(*ReconnectExhausted); exhausted {
+				_ = c.closeWithReason(ReasonRedialExhausted)
+			}
+			c.readErrCh <- fmt.Errorf("Unable to read from %v via proxy: %v", c.addr, err)
+			return
+		}
+		c.markActive()
+
+		eofSignaled := resp.Header.Get(XEnproxyEOF) == "true"
+		var trailingBytes int
+
+		wireBody := &countingReader{Reader: resp.Body}
+		body := io.Reader(wireBody)
+		if c.ResponseBodyTransform != nil {
+			body = c.ResponseBodyTransform(opRead, body)
+		}
+
+		for {
+			n, readErr := c.readWithStallTimeout(body, buf)
+			if n > 0 {
+				if eofSignaled {
+					trailingBytes += n
+				}
+				if !eofSignaled || !c.StrictEOF {
+					chunk := make([]byte, n)
+					copy(chunk, buf[:n])
+
+					var unreadTimeoutCh <-chan time.Time
+					var unreadTimer *time.Timer
+					if c.UnreadDataTimeout > 0 {
+						unreadTimer = time.NewTimer(c.UnreadDataTimeout)
+						unreadTimeoutCh = unreadTimer.C
+					}
+					select {
+					case c.readResponsesCh <- chunk:
+						if unreadTimer != nil {
+							unreadTimer.Stop()
+						}
+					case <-c.closeCh:
+						if unreadTimer != nil {
+							unreadTimer.Stop()
+						}
+						_ = resp.Body.Close()
+						return
+					case <-unreadTimeoutCh:
+						if c.UnreadDataPolicy == UnreadDataDrop {
+							// Drop this chunk and keep streaming, rather
+							// than leaving processReads parked forever
+							// waiting for a Read call that isn't coming.
+							break
+						}
+						// Deliberately not calling resp.Body.Close() here:
+						// for a still-incomplete chunked response, Close
+						// tries to drain the rest of the body so the
+						// connection can be reused, which would block
+						// forever against a proxy that's stopped sending.
+						// closeWithReason's own closeProxyConn tears down
+						// the connection directly instead.
+						_ = c.closeWithReason(ReasonUnreadData)
+						c.readErrCh <- &UnreadDataTimedOut{Waited: c.UnreadDataTimeout}
+						return
+					}
+				}
+			}
+			if mismatch := checkContentLength(resp.ContentLength, wireBody.n, false); mismatch != nil {
+				_ = resp.Body.Close()
+				c.closeProxyConn()
+				c.readErrCh <- mismatch
+				return
+			}
+			if readErr != nil {
+				if stalled, ok := readErr.(*Stalled); ok {
+					// The read goroutine readWithStallTimeout spawned is
+					// still blocked inside resp.Body, holding its internal
+					// lock, so closing resp.Body here would deadlock.
+					// Closing the underlying proxy connection instead
+					// unblocks it and forces the next op to redial.
+					c.closeProxyConn()
+					c.readErrCh <- stalled
+					return
+				}
+				_ = resp.Body.Close()
+				if mismatch := checkContentLength(resp.ContentLength, wireBody.n, true); mismatch != nil {
+					// The proxy connection just delivered a malformed
+					// response, so it's not safe to reuse; redial instead.
+					c.closeProxyConn()
+					c.readErrCh <- mismatch
+					return
+				}
+				if readErr != io.EOF {
+					c.readErrCh <- fmt.Errorf("Error streaming response body from %v via proxy: %v", c.addr, readErr)
+					return
+				}
+				break
+			}
+		}
+
+		if eofSignaled {
+			if trailingBytes > 0 && c.StrictEOF {
+				c.readErrCh <- fmt.Errorf("Received %d bytes of unexpected trailing data from %v after proxy signaled EOF", trailingBytes, c.addr)
+				return
+			}
+			c.readErrCh <- io.EOF
+			return
+		}
+	}
+}
+
+// Write implements io.Writer by sending b to the proxy as a write op. With
+// Config.WriteAckMode set to WriteAckFireAndForget, it returns as soon as
+// the write is queued rather than waiting on doWrite; see WriteAckMode's
+// doc comment for what that trades away.
+func (c *Conn) Write(b []byte) (int, error) {
+	if !c.isConnected() {
+		return 0, &NotConnected{}
+	}
+	if atomic.LoadInt32(&c.quiescing) == 1 {
+		return 0, errQuiescing
+	}
+
+	c.initLimiters()
+	c.writeLimiter.take(len(b))
+	c.initConcurrencyLimits()
+	seq := atomic.AddUint64(&c.writeSeq, 1)
+	if seq == 1 && c.ShapeFirstWrite != nil {
+		b = c.ShapeFirstWrite(b)
+	}
+
+	if c.WriteAckMode == WriteAckFireAndForget {
+		c.goroutineStarted()
+		go func() {
+			defer c.goroutineStopped()
+			_, _ = c.doWrite(b, seq)
+		}()
+		return len(b), nil
+	}
+	return c.doWrite(b, seq)
+}
+
+// doWrite sends b to the proxy as write op seq and applies the response:
+// recording bytes written, and tearing the Conn down on a WriteClosed or an
+// exhausted reconnect, exactly the same whether it's called synchronously
+// from Write or from a WriteAckFireAndForget goroutine that isn't waiting
+// on its result.
+func (c *Conn) doWrite(b []byte, seq uint64) (int, error) {
+	atomic.AddInt32(&c.pendingWrites, 1)
+	defer atomic.AddInt32(&c.pendingWrites, -1)
+
+	queuedAt := time.Now()
+	c.writeSem.acquire()
+	c.recordWriteQueueDelay(time.Since(queuedAt))
+	resp, err := c.doRequest(opWrite, &seqBody{Reader: bytes.NewReader(b), seq: seq})
+	c.writeSem.release()
+	if err != nil {
+		if _, exhausted := err.(*ReconnectExhausted); exhausted {
+			c.goroutineStarted()
+			go func() {
+				defer c.goroutineStopped()
+				_ = c.closeWithReason(ReasonRedialExhausted)
+			}()
+		}
+		return 0, fmt.Errorf("Unable to write to %v via proxy: %v", c.addr, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	c.markActive()
+	if resp.Header.Get(XEnproxyWriteClosed) == "true" {
+		if !c.AllowHalfClosedWrites {
+			c.goroutineStarted()
+			go func() {
+				defer c.goroutineStopped()
+				_ = c.closeWithReason(ReasonError)
+			}()
+		}
+		return 0, &WriteClosed{}
+	}
+	c.recordBytesWritten(len(b))
+	return len(b), nil
+}
+
+// Read implements io.Reader, returning data as soon as processReads makes it
+// available rather than waiting for a full proxy response to complete.
+func (c *Conn) Read(b []byte) (int, error) {
+	if !c.isConnected() {
+		return 0, &NotConnected{}
+	}
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	c.initLimiters()
+
+	if len(c.readBuf) > 0 {
+		n := copy(b, c.readBuf)
+		c.readBuf = c.readBuf[n:]
+		c.readLimiter.take(n)
+		c.recordBytesRead(n)
+		return n, nil
+	}
+
+	if c.peekErr != nil {
+		err := c.peekErr
+		c.peekErr = nil
+		if err != io.EOF {
+			c.goroutineStarted()
+			go func() {
+				defer c.goroutineStopped()
+				_ = c.closeWithReason(ReasonError)
+			}()
+		}
+		return 0, err
+	}
+
+	select {
+	case chunk := <-c.readResponsesCh:
+		n := copy(b, chunk)
+		if n < len(chunk) {
+			c.readBuf = chunk[n:]
+		}
+		c.readLimiter.take(n)
+		c.recordBytesRead(n)
+		return n, nil
+	case err := <-c.readErrCh:
+		if err != io.EOF {
+			c.goroutineStarted()
+			go func() {
+				defer c.goroutineStopped()
+				_ = c.closeWithReason(ReasonError)
+			}()
+		}
+		return 0, err
+	case <-c.closeCh:
+		return 0, io.EOF
+	}
+}
+
+// Close tears down the logical connection, notifying the proxy so that it
+// can release the corresponding destination connection, and fires
+// Config.OnClose with the Conn's final stats.
+func (c *Conn) Close() error {
+	return c.closeWithReason(ReasonExplicit)
+}
+
+// closeWithReason tears down the logical connection exactly once, however
+// many times it's called or from however many goroutines, notifying the
+// proxy only for an explicit close (there's no point telling an already
+// broken connection it's closing), and reports reason and the Conn's final
+// stats via Config.OnClose.
+func (c *Conn) closeWithReason(reason Reason) error {
+	var closeErr error
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+		// processReads may be blocked inside a read op against the proxy
+		// right now; closing its connection unblocks that read with an
+		// error (the same technique used to recover from a *Stalled
+		// read), so it reliably observes closeCh and exits instead of
+		// outliving Close. The close op below redials as needed.
+		c.closeProxyConn()
+		if c.destSlotHeld {
+			c.DestLimiter.release(c.addr)
+		}
+		if reason == ReasonExplicit {
+			resp, err := c.doRequest(opClose, nil)
+			if err != nil {
+				closeErr = fmt.Errorf("Unable to close %v via proxy: %v", c.addr, err)
+			} else {
+				_ = resp.Body.Close()
+			}
+		}
+		if c.Metrics != nil {
+			c.Metrics.Closed(reason, c.stats())
+		}
+		if c.OnClose != nil {
+			c.OnClose(c.stats(), reason)
+		}
+	})
+	return closeErr
+}
+
+// doRequest builds and issues an HTTP request to the proxy for the given op,
+// dialing the proxy connection as necessary. If doRequestOnce fails outright
+// (a dial, write or read failure), the request is retried, after a
+// Config.Backoff delay, only if Config.ShouldRetry says so or, when
+// ShouldRetry is nil, if Config.AutoReconnect allows it for a read or write
+// op; with neither set it isn't retried at all. If the proxy responds 407 and Config.AuthHandler is
+// set, it runs the handler against the challenge and retries with the
+// resulting credentials, up to c.maxAuthRounds() rounds, beyond which it
+// gives up with a *AuthFailed. If the proxy responds 429, it backs off for
+// the duration indicated by Retry-After, falling back to c.Backoff (see
+// Config.Backoff) when Retry-After is absent or unparseable, and retries, up
+// to a cumulative budget of c.maxRateLimitBackoff(), beyond which it gives up
+// with a *RateLimited error.
+func (c *Conn) doRequest(op string, body io.Reader) (resp *http.Response, err error) {
+	atomic.AddInt64(&c.requestsStarted, 1)
+	if c.Metrics != nil {
+		start := time.Now()
+		c.Metrics.RequestStarted(op)
+		defer func() {
+			bytes := 0
+			if err == nil && resp.ContentLength > 0 {
+				bytes = int(resp.ContentLength)
+			}
+			c.Metrics.RequestFinished(op, bytes, time.Since(start), err)
+		}()
+	}
+
+	var totalBackoff time.Duration
+	attempts := 0
+	strategy := c.backoffStrategy()
+	strategy.Reset()
+	authRounds := 0
+	c.pickUserAgent()
+	reqID := uuid.New()
+	for {
+		resp, err := c.doRequestOnce(op, body, reqID)
+		if err != nil {
+			c.recordOpError(op, err)
+			attempts++
+			retry := false
+			switch {
+			case c.ShouldRetry != nil:
+				retry = c.ShouldRetry(op, attempts, err, nil)
+			case c.AutoReconnect:
+				retry = c.shouldAutoReconnect(op, attempts)
+			}
+			if !retry {
+				if c.AutoReconnect && c.ShouldRetry == nil && (op == opRead || op == opWrite) {
+					err = &ReconnectExhausted{Op: op, Attempts: attempts - 1}
+				}
+				return nil, err
+			}
+			if seeker, ok := body.(io.Seeker); ok {
+				if _, serr := seeker.Seek(0, io.SeekStart); serr != nil {
+					return nil, fmt.Errorf("Unable to rewind request body for retry: %v", serr)
+				}
+			}
+			c.fireOnRedial(op, err, attempts)
+			time.Sleep(strategy.NextDelay(attempts))
+			continue
+		}
+
+		if resp.StatusCode == encodingTroubleStatus && c.AutoNegotiateEncoding && !c.useChunkedEncoding() {
+			drainAndClose(resp)
+			c.preferChunkedEncoding()
+			if seeker, ok := body.(io.Seeker); ok {
+				if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+					return nil, fmt.Errorf("Unable to rewind request body for retry: %v", err)
+				}
+			}
+			continue
+		}
+
+		if resp.StatusCode == http.StatusProxyAuthRequired && c.AuthHandler != nil {
+			drainAndClose(resp)
+			authRounds++
+			if authRounds > c.maxAuthRounds() {
+				err := &AuthFailed{Rounds: authRounds - 1}
+				c.recordOpError(op, err)
+				return nil, err
+			}
+			credentials, err := c.AuthHandler(resp.Header.Get("Proxy-Authenticate"))
+			if err != nil {
+				err = fmt.Errorf("Unable to produce proxy credentials: %v", err)
+				c.recordOpError(op, err)
+				return nil, err
+			}
+			c.setProxyAuthorization(credentials)
+			if seeker, ok := body.(io.Seeker); ok {
+				if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+					err = fmt.Errorf("Unable to rewind request body for retry: %v", err)
+					c.recordOpError(op, err)
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			if op == opConnect {
+				c.markConnected()
+			}
+			return resp, nil
+		}
+		drainAndClose(resp)
+		attempts++
+
+		backoff := retryAfter(resp)
+		if backoff <= 0 {
+			backoff = strategy.NextDelay(attempts)
+		}
+		if c.OnRateLimited != nil {
+			c.OnRateLimited(backoff)
+		}
+		totalBackoff += backoff
+		if totalBackoff > c.maxRateLimitBackoff() {
+			err := &RateLimited{Attempts: attempts, TotalBackoff: totalBackoff}
+			c.recordOpError(op, err)
+			return nil, err
+		}
+		if seeker, ok := body.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				err = fmt.Errorf("Unable to rewind request body for retry: %v", err)
+				c.recordOpError(op, err)
+				return nil, err
+			}
+		}
+		time.Sleep(backoff)
+	}
+}
+
+// doRequestOnce builds and issues a single HTTP request to the proxy for the
+// given op, dialing the proxy connection as necessary. reqID is set on the
+// request as XEnproxyReqID (unless Config.CompactHeaders omits it) and passed to Config.OnResponseHeaders once a
+// response comes back.
+//
+// The proxy connection's actual request/response I/O happens under
+// reqMutex: processReads keeps a read op's request in flight, streaming its
+// response body long after doRequestOnce itself returns, and without this a
+// concurrent Write racing doRequestOnce for the same connection would write
+// its own request and parse its own response against the same
+// net.Conn/bufio.Reader pair at the same time, corrupting the HTTP framing
+// both are relying on. net.Conn promises Read and Write are independently
+// safe to call from different goroutines, but that's not what's happening
+// here -- two goroutines would be multiplexing distinct HTTP exchanges onto
+// the one byte stream, which HTTP/1.1 doesn't support. reqMutex is released
+// as soon as there's nothing left of this exchange for another op to
+// collide with: immediately, for a compact framing response (always fully
+// assembled in memory by the time doRequestOnceLocked returns, so there's no
+// wire I/O left to race) or a response with no body left to read off the
+// wire (true of every other op except a connect with OptimisticReadWindow or
+// a read that actually got data back), or once the caller has read the
+// response body to completion or closed it otherwise.
+func (c *Conn) doRequestOnce(op string, body io.Reader, reqID string) (*http.Response, error) {
+	if c.Chaos != nil {
+		c.Chaos.applyLatency()
+		if c.Chaos.shouldDrop() {
+			return nil, &chaosDropped{}
+		}
+	}
+
+	conn, reader, warm, err := c.getProxyConn()
+	if err != nil {
+		return nil, err
+	}
+	if c.Metrics != nil {
+		c.Metrics.ConnReused(op, warm)
+	}
+
+	c.reqMutex.Lock()
+	resp, err := c.doRequestOnceLocked(conn, reader, op, body, reqID)
+	if err != nil {
+		c.reqMutex.Unlock()
+		return nil, err
+	}
+	if c.isUpgradedToCompactFraming() || (resp.ContentLength == 0 && len(resp.TransferEncoding) == 0) {
+		c.reqMutex.Unlock()
+	} else {
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: c.reqMutex.Unlock}
+	}
+	return resp, nil
+}
+
+// doRequestOnceLocked does the actual work of doRequestOnce, run under
+// reqMutex; see its doc comment for why.
+func (c *Conn) doRequestOnceLocked(conn net.Conn, reader *bufio.Reader, op string, body io.Reader, reqID string) (*http.Response, error) {
+	if c.isUpgradedToCompactFraming() {
+		return c.doCompactRequestAsResponse(conn, reader, op, body)
+	}
+
+	reqBody, err := c.encryptRequestBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.NewRequest(c.Host, "POST", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to construct request: %v", err)
+	}
+	for k, vv := range c.OpHeaders[op] {
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+	if auth := c.proxyAuthorization(); auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+	c.setIdentityHeaders(req.Header, op, reqID)
+	if op == opWrite {
+		if sb, ok := body.(*seqBody); ok {
+			req.Header.Set(XEnproxyWriteSeq, strconv.FormatUint(sb.WriteSeq(), 10))
+		}
+		if c.WriteAckMode == WriteAckDestWritten {
+			req.Header.Set(XEnproxyWriteAckMode, c.WriteAckMode)
+		}
+	}
+	if op == opConnect && c.CompactFraming {
+		req.Header.Set(compactUpgradeHeader, "1")
+	}
+	if op == opConnect && c.OptimisticReadWindow > 0 {
+		req.Header.Set(XEnproxyOptimisticReadWindow, strconv.Itoa(c.OptimisticReadWindow))
+	}
+	if op == opRead && c.ReadBatchMaxBytes > 0 {
+		req.Header.Set(XEnproxyReadBatchMaxBytes, strconv.Itoa(c.ReadBatchMaxBytes))
+		if c.ReadBatchMaxWait > 0 {
+			req.Header.Set(XEnproxyReadBatchMaxWait, strconv.FormatInt(c.ReadBatchMaxWait.Milliseconds(), 10))
+		}
+	}
+	c.requestDiagnosticsIfConfigured(op, req.Header)
+	c.setAltDestHeaders(op, req.Header)
+	if body == nil && c.OmitContentTypeOnEmptyBody {
+		req.Header.Del("Content-Type")
+	}
+	if c.useChunkedEncoding() {
+		req.TransferEncoding = []string{"chunked"}
+	}
+
+	if c.RequestInterceptor != nil {
+		if err := c.RequestInterceptor(req); err != nil {
+			return nil, fmt.Errorf("RequestInterceptor rejected request: %v", err)
+		}
+	}
+
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("Unable to write request: %v", err)
+	}
+
+	resp, err := readFinalResponse(reader, req)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response: %v", err)
+	}
+
+	if c.OnResponseHeaders != nil {
+		c.OnResponseHeaders(op, reqID, resp.Header)
+	}
+	c.recordForwardedHeaders(resp.Header)
+
+	if op == opConnect {
+		c.recordDiagnostics(resp.Header)
+		c.recordChosenDest(resp.Header)
+		c.recordClockSkew(resp.Header)
+		c.recordResolvedDest(resp.Header)
+	}
+
+	if resp.Close {
+		// The proxy is about to recycle this socket, so stop offering it up
+		// for reuse. We still let the caller finish reading resp.Body off of
+		// it before it actually gets closed.
+		c.invalidateProxyConn(conn)
+		resp.Body = &closeConnOnClose{ReadCloser: resp.Body, conn: conn}
+	}
+
+	if c.Chaos != nil && c.Chaos.shouldCorrupt() {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{&corruptingReader{resp.Body}, resp.Body}
+	}
+
+	if err := c.decryptResponseBody(resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// readFinalResponse reads a response off reader, discarding and re-reading
+// past any 1xx informational responses (100 Continue, 103 Early Hints, and
+// so on) that precede the actual final one, since http.ReadResponse itself
+// doesn't skip these. 101 Switching Protocols is left alone rather than
+// skipped, since that's the final response for a CompactFraming upgrade
+// request, not an informational one awaiting a follow-up.
+func readFinalResponse(reader *bufio.Reader, req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := http.ReadResponse(reader, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 100 || resp.StatusCode >= 200 || resp.StatusCode == http.StatusSwitchingProtocols {
+			return resp, nil
+		}
+		_ = resp.Body.Close()
+	}
+}
+
+// invalidateProxyConn stops offering conn up for reuse if it's still the
+// currently cached connection, so that the next doRequest call redials.
+func (c *Conn) invalidateProxyConn(conn net.Conn) {
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	if c.ci != nil && c.ci.conn == conn {
+		c.ci = nil
+	}
+}
+
+// closeProxyConn closes and stops offering up the current proxy connection,
+// so the next doRequest call dials a fresh one.
+func (c *Conn) closeProxyConn() {
+	c.connInfoMutex.Lock()
+	ci := c.ci
+	c.ci = nil
+	c.connInfoMutex.Unlock()
+	if ci != nil {
+		_ = ci.conn.Close()
+	}
+}
+
+// closeConnOnClose closes the underlying proxy connection once the response
+// body that was read from it has been closed.
+type closeConnOnClose struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (c *closeConnOnClose) Close() error {
+	bodyErr := c.ReadCloser.Close()
+	connErr := c.conn.Close()
+	if bodyErr != nil {
+		return bodyErr
+	}
+	return connErr
+}
+
+// releasingBody runs release exactly once -- whether the caller reads it to
+// EOF and never explicitly closes it, the common case for a small
+// request/response exchange, or calls Close directly instead -- so
+// doRequestOnce can hand back reqMutex as soon as the caller is done with a
+// response, however it gets there.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.releaseOnce()
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	b.releaseOnce()
+	return b.ReadCloser.Close()
+}
+
+func (b *releasingBody) releaseOnce() {
+	b.once.Do(b.release)
+}
+
+// getProxyConn returns the current connection to the proxy, dialing a new
+// one if none exists yet. warm reports which of those happened: true if an
+// already-established connection was reused, false if this call just
+// dialed a fresh one -- see Config.Metrics's ConnReused for why a caller
+// cares.
+func (c *Conn) getProxyConn() (conn net.Conn, reader *bufio.Reader, warm bool, err error) {
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+
+	if c.ci != nil {
+		if c.shouldPingBeforeReuse() && c.pingProxyConn(c.ci.conn, c.ci.reader) != nil {
+			_ = c.ci.conn.Close()
+			c.ci = nil
+		} else if c.ValidateProxyConn == nil || c.ValidateProxyConn(CachedConn{Conn: c.ci.conn, LastActivity: c.lastActivity()}) {
+			return c.ci.conn, c.ci.reader, true, nil
+		} else {
+			_ = c.ci.conn.Close()
+			c.ci = nil
+		}
+	}
+
+	if c.DialLimiter != nil {
+		if err := c.DialLimiter.acquire(); err != nil {
+			return nil, nil, false, err
+		}
+		defer c.DialLimiter.release()
+	}
+
+	dialStart := time.Now()
+	dialedConn, err := c.DialProxy(c.Host)
+	c.recordProxyDialTiming(time.Since(dialStart))
+	if err != nil {
+		if noHealthy, ok := err.(*NoHealthyProxy); ok && c.FailFastWhenNoHealthy {
+			return nil, nil, false, noHealthy
+		}
+		return nil, nil, false, fmt.Errorf("Unable to dial proxy: %v", err)
+	}
+	dialedConn, err = c.setUpDialedConn(dialedConn)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	c.ci = &connInfo{conn: dialedConn, reader: bufio.NewReader(dialedConn)}
+	c.markActive()
+	return c.ci.conn, c.ci.reader, false, nil
+}
+
+// setUpDialedConn applies the socket- and transport-level settings any
+// freshly dialed proxy connection needs, whether it came from DialProxy
+// during an ordinary redial or from a MigrateTo selector.
+func (c *Conn) setUpDialedConn(conn net.Conn) (net.Conn, error) {
+	if err := c.maybeSetLinger(conn); err != nil {
+		return nil, fmt.Errorf("Unable to set linger on proxy socket: %v", err)
+	}
+	if err := c.maybeApplyBufferSizes(conn); err != nil {
+		return nil, err
+	}
+	if c.WrapProxyConn != nil {
+		conn = c.WrapProxyConn(conn)
+	}
+	tlsStart := time.Now()
+	conn, err := c.maybeWrapTLS(conn)
+	if c.TLS {
+		c.recordTLSHandshakeTiming(time.Since(tlsStart))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.maybeWrapIdleTiming(conn), nil
+}
+
+// markActive records that the connection was just used, for idle tracking.
+func (c *Conn) markActive() {
+	atomic.StoreInt64(&c.lastActivityNanos, time.Now().UnixNano())
+}
+
+// lastActivity returns when the proxy connection was last used, per
+// markActive. Zero if markActive has never been called.
+func (c *Conn) lastActivity() time.Time {
+	nanos := atomic.LoadInt64(&c.lastActivityNanos)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// isUpgradedToCompactFraming reports whether the proxy has already agreed to
+// switch this Conn's connection to compact binary framing.
+func (c *Conn) isUpgradedToCompactFraming() bool {
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	return c.ci != nil && c.ci.upgraded
+}
+
+// doCompactRequestAsResponse issues op using compact framing and adapts the
+// result to look like an *http.Response, so that callers elsewhere in Conn
+// don't need to know which framing is in use.
+func (c *Conn) doCompactRequestAsResponse(conn net.Conn, reader *bufio.Reader, op string, body io.Reader) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read request body for compact framing: %v", err)
+		}
+	}
+
+	respPayload, err := c.doCompactRequest(conn, reader, opToFrameOp(op), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		// ContentLength is left unknown (-1) rather than 0: a compact frame's
+		// payload length isn't carried anywhere an *http.Response normally
+		// looks for it, so declaring 0 here would make processReads flag any
+		// read op that actually returns data as an *OverlongResponse.
+		ContentLength: -1,
+		Body:          io.NopCloser(bytes.NewReader(respPayload)),
+	}, nil
+}
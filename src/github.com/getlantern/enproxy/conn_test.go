@@ -0,0 +1,71 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowStreamingProxy behaves like serveFakeProxy except that, for read ops,
+// it writes its response in two separately-flushed chunks with a pause in
+// between, so that tests can observe whether a reader gets the first chunk
+// before the second has been sent.
+func slowStreamingProxy(conn net.Conn, chunkSent chan<- struct{}) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+
+		if req.Header.Get(XEnproxyOp) != opRead {
+			_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+			continue
+		}
+
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n"))
+		_, _ = conn.Write([]byte("5\r\nhello\r\n"))
+		chunkSent <- struct{}{}
+		time.Sleep(100 * time.Millisecond)
+		_, _ = conn.Write([]byte("5\r\nworld\r\n0\r\n\r\n"))
+		return
+	}
+}
+
+func TestReadStreamsPartialData(t *testing.T) {
+	chunkSent := make(chan struct{}, 1)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go slowStreamingProxy(server, chunkSent)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	<-chunkSent
+
+	b := make([]byte, 5)
+	n, err := conn.Read(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "hello", string(b[:n]), "should receive first chunk before second has arrived")
+
+	n, err = conn.Read(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "world", string(b[:n]), "should receive second chunk once it arrives")
+}
@@ -0,0 +1,29 @@
+package enproxy
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultConnectTimeout is used when Config.ConnectTimeout is unset.
+const defaultConnectTimeout = 30 * time.Second
+
+// ConnectTimeout is returned by Dial when Config.ConnectTimeout elapses
+// before the tunnel is established.
+type ConnectTimeout struct {
+	// Budget is the Config.ConnectTimeout (or its default) that elapsed.
+	Budget time.Duration
+}
+
+func (e *ConnectTimeout) Error() string {
+	return fmt.Sprintf("tunnel not established within %s", e.Budget)
+}
+
+// connectTimeout returns c.ConnectTimeout, or defaultConnectTimeout if
+// unset.
+func (c *Conn) connectTimeout() time.Duration {
+	if c.ConnectTimeout > 0 {
+		return c.ConnectTimeout
+	}
+	return defaultConnectTimeout
+}
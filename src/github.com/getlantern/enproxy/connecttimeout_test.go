@@ -0,0 +1,51 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// neverRespondingProxy accepts the connection but never writes a response,
+// simulating a proxy that's hung or unreachable past the TCP handshake.
+func neverRespondingProxy(conn net.Conn, done <-chan struct{}) {
+	<-done
+	_ = conn.Close()
+}
+
+func TestDialFailsWithConnectTimeoutWhenProxyNeverResponds(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	cfg := testConfig()
+	cfg.ConnectTimeout = 20 * time.Millisecond
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go neverRespondingProxy(server, done)
+		return client, nil
+	}
+
+	_, err := Dial("dest.example.com:80", cfg)
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	_, ok := err.(*ConnectTimeout)
+	assert.True(t, ok, "expected a *ConnectTimeout, got %T: %v", err, err)
+}
+
+func TestDialSucceedsWithinConnectTimeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.ConnectTimeout = time.Second
+
+	conn, err := Dial("dest.example.com:80", cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = conn.Close()
+}
+
+func TestConnectTimeoutDefaultsWhenUnset(t *testing.T) {
+	conn := testConfig().NewConn("dest.example.com:80")
+	assert.Equal(t, defaultConnectTimeout, conn.connectTimeout())
+}
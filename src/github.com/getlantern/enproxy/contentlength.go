@@ -0,0 +1,74 @@
+package enproxy
+
+import (
+	"fmt"
+	"io"
+)
+
+// TruncatedResponse is returned from Read when a read op's response
+// declared a Content-Length but the proxy connection closed before that
+// many bytes of body arrived, typically because a flaky middlebox cut the
+// response short. It triggers the same redial path as a *Stalled error.
+type TruncatedResponse struct {
+	// Declared is the Content-Length the response header promised.
+	Declared int64
+
+	// Received is how many body bytes actually arrived before the
+	// connection closed.
+	Received int64
+}
+
+func (e *TruncatedResponse) Error() string {
+	return fmt.Sprintf("response declared Content-Length %d but connection closed after only %d bytes", e.Declared, e.Received)
+}
+
+// OverlongResponse is returned from Read if a read op's response body
+// somehow delivers more bytes than its declared Content-Length. net/http
+// already enforces Content-Length as a hard cap when reading resp.Body, so
+// in practice this should be unreachable; it's checked anyway as a
+// defensive guard against wire framing bugs, in case a future transport
+// changes that.
+type OverlongResponse struct {
+	// Declared is the Content-Length the response header promised.
+	Declared int64
+
+	// Received is how many body bytes arrived, exceeding Declared.
+	Received int64
+}
+
+func (e *OverlongResponse) Error() string {
+	return fmt.Sprintf("response declared Content-Length %d but %d bytes arrived", e.Declared, e.Received)
+}
+
+// checkContentLength compares received against declared (resp.ContentLength
+// as read so far), returning a *TruncatedResponse or *OverlongResponse on
+// mismatch, or nil if nothing's wrong yet. A negative declared means the
+// response didn't advertise a Content-Length (for example it's chunked),
+// in which case there's nothing to check. complete should be true once the
+// body read has ended (whether by EOF or error), since received being
+// short of declared is only a problem once no more bytes are coming.
+func checkContentLength(declared, received int64, complete bool) error {
+	if declared < 0 {
+		return nil
+	}
+	if received > declared {
+		return &OverlongResponse{Declared: declared, Received: received}
+	}
+	if complete && received < declared {
+		return &TruncatedResponse{Declared: declared, Received: received}
+	}
+	return nil
+}
+
+// countingReader wraps an io.Reader, tracking how many bytes it's yielded
+// so far in n.
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
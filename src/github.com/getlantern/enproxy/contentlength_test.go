@@ -0,0 +1,86 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// truncatingProxy responds normally to the connect op, then on the first
+// read op declares a Content-Length longer than the body it actually
+// sends before closing the connection, simulating a middlebox that cuts a
+// response short.
+func truncatingProxy(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\nhello"))
+	_ = conn.Close()
+}
+
+func TestReadReturnsTruncatedResponseOnShortBody(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go truncatingProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	b := make([]byte, 10)
+	n, err := conn.Read(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "hello", string(b[:n]))
+
+	_, err = conn.Read(b)
+	assert.Error(t, err)
+	truncated, ok := err.(*TruncatedResponse)
+	if !assert.True(t, ok, "expected a *TruncatedResponse error, got %T: %v", err, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, int64(10), truncated.Declared)
+	assert.Equal(t, int64(5), truncated.Received)
+}
+
+func TestCheckContentLengthCatchesOverlongBody(t *testing.T) {
+	// net/http caps resp.Body reads at the declared Content-Length, so an
+	// overlong body can't actually reach processReads; exercise the
+	// detection logic itself instead, as a defensive guard.
+	err := checkContentLength(10, 11, false)
+	overlong, ok := err.(*OverlongResponse)
+	if !assert.True(t, ok, "expected a *OverlongResponse error, got %T: %v", err, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, int64(10), overlong.Declared)
+	assert.Equal(t, int64(11), overlong.Received)
+}
+
+func TestCheckContentLengthIgnoresUnknownLength(t *testing.T) {
+	assert.NoError(t, checkContentLength(-1, 0, true))
+	assert.NoError(t, checkContentLength(-1, 1000, true))
+}
+
+func TestCheckContentLengthWaitsForCompletionBeforeFlaggingShortfall(t *testing.T) {
+	assert.NoError(t, checkContentLength(10, 5, false))
+}
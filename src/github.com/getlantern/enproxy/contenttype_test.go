@@ -0,0 +1,79 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// contentTypeCapturingProxy answers the connect op, recording whether it
+// saw a Content-Type header.
+func contentTypeCapturingProxy(conn net.Conn, sawCh chan<- bool) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	sawCh <- req.Header.Get("Content-Type") != ""
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestOmitContentTypeOnEmptyBodyStripsHeaderForBodylessOps(t *testing.T) {
+	sawCh := make(chan bool, 1)
+	cfg := testConfig()
+	cfg.OmitContentTypeOnEmptyBody = true
+	cfg.NewRequest = func(upstreamHost string, method string, body io.Reader) (*http.Request, error) {
+		req, err := http.NewRequest(method, "http://"+upstreamHost, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go contentTypeCapturingProxy(server, sawCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.False(t, <-sawCh, "expected Content-Type to be stripped from a bodyless op")
+}
+
+func TestContentTypePreservedByDefaultForBodylessOps(t *testing.T) {
+	sawCh := make(chan bool, 1)
+	cfg := testConfig()
+	cfg.NewRequest = func(upstreamHost string, method string, body io.Reader) (*http.Request, error) {
+		req, err := http.NewRequest(method, "http://"+upstreamHost, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go contentTypeCapturingProxy(server, sawCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.True(t, <-sawCh, "expected Content-Type to be left alone by default")
+}
@@ -0,0 +1,18 @@
+package enproxy
+
+// CurrentProxyAddr returns the remote address of the proxy connection c is
+// currently using, and true, or false if c isn't currently holding a proxy
+// connection (before the first successful dial, or in the brief window
+// between a redial's old connection closing and its replacement being
+// dialed). When Config.DialProxy came from Pool.DialProxy, pair this with
+// Pool.Stats to see the health of the specific member behind this Conn
+// right now; Conn itself has no notion of a pool or a selector, since
+// Config.DialProxy is just a plain dial function as far as it's concerned.
+func (c *Conn) CurrentProxyAddr() (string, bool) {
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	if c.ci == nil {
+		return "", false
+	}
+	return c.ci.conn.RemoteAddr().String(), true
+}
@@ -0,0 +1,83 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCurrentProxyAddr confirms CurrentProxyAddr reports the dialed
+// connection's remote address once connected, false before that, and pairs
+// correctly with Pool.Stats when Config.DialProxy came from Pool.DialProxy.
+func TestCurrentProxyAddr(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	_, ok := conn.CurrentProxyAddr()
+	assert.False(t, ok, "no proxy connection dialed yet")
+
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	addr, ok := conn.CurrentProxyAddr()
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.NotEmpty(t, addr)
+}
+
+// TestCurrentProxyAddrWithPool confirms CurrentProxyAddr reports whichever
+// pool member Pool.DialProxy actually selected (via its real, distinct
+// TCP address), so Pool.Stats(addr) can be used to inspect that member's
+// health.
+func TestCurrentProxyAddrWithPool(t *testing.T) {
+	members := make([]string, 2)
+	for i := range members {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer ln.Close()
+		members[i] = ln.Addr().String()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go serveFakeProxy(conn)
+			}
+		}()
+	}
+
+	pool := NewPool(members)
+	cfg := testConfig()
+	cfg.DialProxy = pool.DialProxy(func(addr string) (net.Conn, error) {
+		return net.Dial("tcp", addr)
+	})
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	addr, ok := conn.CurrentProxyAddr()
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	if !assert.Contains(t, members, addr) {
+		t.FailNow()
+	}
+
+	stats, ok := pool.Stats(addr)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.True(t, stats.Healthy)
+}
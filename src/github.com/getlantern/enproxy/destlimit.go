@@ -0,0 +1,65 @@
+package enproxy
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TooManyTunnels is returned by Connect when a DestLimiter has already
+// reached its per-destination limit and Config.BlockOnDestLimit is false.
+type TooManyTunnels struct {
+	// Addr is the destination that was at its limit.
+	Addr string
+	// Limit is the per-destination limit that was reached.
+	Limit int
+}
+
+func (e *TooManyTunnels) Error() string {
+	return fmt.Sprintf("already %d tunnels open to %v, the configured limit", e.Limit, e.Addr)
+}
+
+// DestLimiter caps how many Conns may be concurrently open to any one
+// destination address across every Conn sharing it via Config.DestLimiter,
+// so a client doesn't trip a well-behaved origin's own connection limits.
+// It's safe for concurrent use.
+type DestLimiter struct {
+	max int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	inFlight map[string]int
+}
+
+// NewDestLimiter creates a DestLimiter allowing up to max concurrent Conns
+// to any single destination.
+func NewDestLimiter(max int) *DestLimiter {
+	l := &DestLimiter{max: max, inFlight: make(map[string]int)}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire reserves a slot for addr, blocking until one is free if block is
+// true, or returning a *TooManyTunnels error immediately if not.
+func (l *DestLimiter) acquire(addr string, block bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.inFlight[addr] >= l.max {
+		if !block {
+			return &TooManyTunnels{Addr: addr, Limit: l.max}
+		}
+		l.cond.Wait()
+	}
+	l.inFlight[addr]++
+	return nil
+}
+
+// release frees up addr's slot, waking any Conn blocked in acquire.
+func (l *DestLimiter) release(addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[addr] > 0 {
+		l.inFlight[addr]--
+	}
+	l.cond.Broadcast()
+}
@@ -0,0 +1,70 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestLimiterRejectsWhenFull(t *testing.T) {
+	limiter := NewDestLimiter(1)
+
+	cfg := testConfig()
+	cfg.DestLimiter = limiter
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	first := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, first.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = first.Close() }()
+
+	second := cfg.NewConn("dest.example.com:80")
+	err := second.Connect()
+	_, ok := err.(*TooManyTunnels)
+	assert.True(t, ok, "expected a *TooManyTunnels error, got %T: %v", err, err)
+}
+
+func TestDestLimiterBlocksUntilSlotFrees(t *testing.T) {
+	limiter := NewDestLimiter(1)
+
+	cfg := testConfig()
+	cfg.DestLimiter = limiter
+	cfg.BlockOnDestLimit = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	first := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, first.Connect()) {
+		t.FailNow()
+	}
+
+	done := make(chan error, 1)
+	second := cfg.NewConn("dest.example.com:80")
+	go func() { done <- second.Connect() }()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("second Connect should still be blocked on the dest limit")
+	default:
+	}
+
+	assert.NoError(t, first.Close())
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("second Connect should have unblocked after first Close")
+	}
+}
@@ -0,0 +1,68 @@
+package enproxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// detachQuiesceTimeout bounds how long Detach will wait for in-flight
+// writes to finish before giving up.
+const detachQuiesceTimeout = 5 * time.Second
+
+// DetachedState is the minimal information Detach captures from a Conn so
+// that Attach can rebuild an equivalent Conn in a forked child process. Its
+// fields are plain values so it can be gob- or JSON-encoded and handed
+// across a fork without carrying any of the live goroutines, sockets or
+// channels that don't survive one.
+//
+// Reattaching to the same logical connection only works if the proxy
+// grants a resume grace window for the connection id after the parent
+// stops polling it; this package doesn't implement or negotiate any such
+// window (see ActiveFeatures.Resume, which is always false today), so
+// Attach is only safe against a proxy deployment known out-of-band to
+// support resume, and only within whatever grace period that deployment
+// promises. Outside of that window, the proxy is free to treat the
+// reattached id as stale and the tunnel as lost.
+type DetachedState struct {
+	ID       string
+	Addr     string
+	AltAddrs []string
+}
+
+// Detach quiesces c, refusing further writes, then captures enough state
+// for Attach to rebuild an equivalent Conn in a child process after a
+// fork. It does not close c; the parent should still Close it once the
+// fork completes, since c's background goroutine and proxy connection are
+// not themselves fork-safe and must not be touched again by the parent
+// afterward.
+func (c *Conn) Detach() (DetachedState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), detachQuiesceTimeout)
+	defer cancel()
+	if err := c.Quiesce(ctx); err != nil {
+		return DetachedState{}, fmt.Errorf("Unable to quiesce before detach: %v", err)
+	}
+	return DetachedState{
+		ID:       c.id,
+		Addr:     c.addr,
+		AltAddrs: append([]string(nil), c.altAddrs...),
+	}, nil
+}
+
+// Attach rebuilds a Conn from state captured by Detach, normally in a
+// freshly forked child process where the parent's original Conn's
+// goroutine and proxy socket no longer work. config is used as-is to dial
+// and authenticate the new proxy connection; it need not be (and usually
+// isn't) identical to the parent's Config, but should target the same
+// proxy deployment for resume to have any chance of working.
+//
+// The returned Conn still needs Connect called on it. Whether the proxy
+// treats that as resuming the original tunnel, rather than starting a new
+// one that happens to reuse an id, is entirely up to the proxy's own grace
+// window, which this package has no way to query or enforce.
+func Attach(state DetachedState, config *Config) *Conn {
+	conn := config.NewConn(state.Addr)
+	conn.id = state.ID
+	conn.altAddrs = append([]string(nil), state.AltAddrs...)
+	return conn
+}
@@ -0,0 +1,86 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// idEchoingProxy answers every request with a plain 200, recording the
+// XEnproxyID it saw on each one.
+func idEchoingProxy(conn net.Conn, idsCh chan<- string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		idsCh <- req.Header.Get(XEnproxyID)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestAttachReusesDetachedID(t *testing.T) {
+	idsCh := make(chan string, 2)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go idEchoingProxy(server, idsCh)
+		return client, nil
+	}
+
+	original := cfg.NewConn("dest.example.com:80")
+	resp, err := original.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	originalID := <-idsCh
+
+	state, err := original.Detach()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, originalID, state.ID)
+	assert.Equal(t, "dest.example.com:80", state.Addr)
+
+	attached := Attach(state, &cfg)
+	assert.Equal(t, original.addr, attached.addr)
+
+	resp, err = attached.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	attachedID := <-idsCh
+
+	assert.Equal(t, originalID, attachedID, "attached Conn should present the same id to the proxy")
+}
+
+func TestDetachRefusesFurtherWrites(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go idEchoingProxy(server, make(chan string, 1))
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	_, err = conn.Detach()
+	assert.NoError(t, err)
+
+	_, err = conn.Write([]byte("hello"))
+	assert.Error(t, err, "a detached Conn has been quiesced and shouldn't accept new writes")
+}
@@ -0,0 +1,89 @@
+package enproxy
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// XEnproxyDiagnostics, when sent with a connect request, asks the proxy to
+// include Diagnostics (JSON-encoded) in its response. Servers should treat
+// this as opt-in and leave it disabled by default, since it can reveal
+// internal topology (edge identity, destination connection state) to
+// whoever can reach the proxy.
+const XEnproxyDiagnostics = "X-Enproxy-Diagnostics"
+
+// Diagnostics carries proxy-side debugging info about a tunnel, reported by
+// servers that support it, for use by apps debugging "why is my tunnel
+// slow" without access to server-side logs.
+type Diagnostics struct {
+	// EdgeID identifies which proxy edge/instance served the connection.
+	EdgeID string `json:"edgeId"`
+
+	// DestResolutionTime is how long the proxy took to resolve the
+	// destination address.
+	DestResolutionTime time.Duration `json:"destResolutionTime"`
+
+	// DestConnState describes the proxy's connection to the destination,
+	// e.g. "connected", "closed".
+	DestConnState string `json:"destConnState"`
+
+	// DestWriteBufferBytes is how much outgoing data the proxy is
+	// currently holding for this connection, buffered because the
+	// destination is draining it slower than the client is writing.
+	// Reported by servers that support it, for spotting a slow
+	// destination before it forces the server to apply backpressure.
+	// Capping that buffer and applying backpressure when it's hit are
+	// server-side behaviors this client-only package has no part in;
+	// this field just lets an app observe the number a server already
+	// tracks.
+	DestWriteBufferBytes int64 `json:"destWriteBufferBytes"`
+}
+
+// requestDiagnosticsIfConfigured sets the diagnostics opt-in header on a
+// connect request when c.RequestDiagnostics is enabled. Diagnostics are off
+// by default; a Conn only asks for them explicitly.
+func (c *Conn) requestDiagnosticsIfConfigured(op string, header headerSetter) {
+	if op == opConnect && c.RequestDiagnostics {
+		header.Set(XEnproxyDiagnostics, "1")
+	}
+}
+
+// headerSetter is satisfied by http.Header, kept narrow so
+// requestDiagnosticsIfConfigured doesn't need to know about *http.Request.
+type headerSetter interface {
+	Set(key, value string)
+}
+
+// recordDiagnostics parses a connect response's diagnostics header, if
+// present, caching it for later retrieval via Diagnostics.
+func (c *Conn) recordDiagnostics(header headerGetter) {
+	raw := header.Get(XEnproxyDiagnostics)
+	if raw == "" {
+		return
+	}
+	var d Diagnostics
+	if err := json.Unmarshal([]byte(raw), &d); err != nil {
+		log.Debugf("Unable to parse diagnostics from proxy: %v", err)
+		return
+	}
+	c.diagnosticsMutex.Lock()
+	c.diagnostics = &d
+	c.diagnosticsMutex.Unlock()
+}
+
+// headerGetter is satisfied by http.Header.
+type headerGetter interface {
+	Get(key string) string
+}
+
+// Diagnostics returns the proxy-reported diagnostics for this Conn, if the
+// proxy supported and returned them in response to RequestDiagnostics. The
+// second return value is false if no diagnostics are available.
+func (c *Conn) Diagnostics() (Diagnostics, bool) {
+	c.diagnosticsMutex.Lock()
+	defer c.diagnosticsMutex.Unlock()
+	if c.diagnostics == nil {
+		return Diagnostics{}, false
+	}
+	return *c.diagnostics, true
+}
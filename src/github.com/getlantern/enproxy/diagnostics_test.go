@@ -0,0 +1,71 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// diagnosticsProxy responds to a connect request with diagnostics only if
+// the request opted in, simulating a server that supports but gates the
+// feature.
+func diagnosticsProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+
+	if req.Header.Get(XEnproxyDiagnostics) == "" {
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+		return
+	}
+	body := `{"edgeId":"edge-1","destResolutionTime":1500000,"destConnState":"connected","destWriteBufferBytes":4096}`
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\n" + XEnproxyDiagnostics + ": " + body + "\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestRequestDiagnostics(t *testing.T) {
+	cfg := testConfig()
+	cfg.RequestDiagnostics = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go diagnosticsProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	d, ok := conn.Diagnostics()
+	if !assert.True(t, ok, "expected diagnostics to be populated") {
+		t.FailNow()
+	}
+	assert.Equal(t, "edge-1", d.EdgeID)
+	assert.Equal(t, "connected", d.DestConnState)
+	assert.Equal(t, int64(4096), d.DestWriteBufferBytes)
+}
+
+func TestDiagnosticsNotRequestedByDefault(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go diagnosticsProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	_, ok := conn.Diagnostics()
+	assert.False(t, ok, "diagnostics should not be populated unless requested")
+}
@@ -0,0 +1,76 @@
+package enproxy
+
+import (
+	"net"
+	"time"
+)
+
+// Dial creates a Conn to addr using cfg and performs its connect handshake,
+// the whole of which must complete within cfg's ConnectTimeout or Dial
+// aborts with a *ConnectTimeout. It's a convenience wrapper around
+// cfg.NewConn and Conn.Connect for callers that don't need to separate
+// construction from connecting. Because Dial doesn't return until Connect
+// has already succeeded, there's no window after Dial returns where a
+// Write or Read could race ahead of the connect op -- the returned Conn is
+// always fully connected. Only cfg.NewConn followed directly by Write or
+// Read, skipping Connect entirely, can hit that case; see NotConnected.
+func Dial(addr string, cfg Config) (*Conn, error) {
+	conn := cfg.NewConn(addr)
+	timeout := conn.connectTimeout()
+
+	connectErrCh := make(chan error, 1)
+	go func() { connectErrCh <- conn.Connect() }()
+
+	select {
+	case err := <-connectErrCh:
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	case <-time.After(timeout):
+		// Connect may still succeed after we give up on it; let it finish
+		// in the background and clean up after itself rather than leaking
+		// the goroutine, closing the now-unwanted Conn if it does.
+		go func() {
+			if err := <-connectErrCh; err == nil {
+				_ = conn.Close()
+			}
+		}()
+		return nil, &ConnectTimeout{Budget: timeout}
+	}
+}
+
+// DialHappyEyeballs returns a DialProxy function that dials the proxy with
+// Happy Eyeballs (RFC 8305): when addr's host resolves to both IPv4 and
+// IPv6 addresses, it races a connection to each family and uses whichever
+// completes first, giving the one dialed first a fallbackDelay head start
+// before the other is even attempted. A zero fallbackDelay uses
+// net.Dialer's own 300ms default; pass a negative value to dial families
+// sequentially instead of racing them. This only helps against a proxy
+// host with addresses in both families; reached over a single family, or
+// by IP, it behaves like an ordinary dial. Unlike DialTCP, it doesn't
+// consult a DNSCache, since the racing itself is what does the resolving.
+func DialHappyEyeballs(fallbackDelay time.Duration) func(addr string) (net.Conn, error) {
+	dialer := &net.Dialer{FallbackDelay: fallbackDelay}
+	return func(addr string) (net.Conn, error) {
+		return dialer.Dial("tcp", addr)
+	}
+}
+
+// DialTCP returns a DialProxy function that dials the proxy over plain TCP,
+// resolving proxyAddr through cache if non-nil. Use this as Config.DialProxy
+// when the proxy is reached directly (as opposed to through a CONNECT or
+// SOCKS hop) and repeated resolution of the same proxy address would
+// otherwise be wasteful.
+func DialTCP(cache *DNSCache) func(addr string) (net.Conn, error) {
+	return func(addr string) (net.Conn, error) {
+		if cache == nil {
+			return net.Dial("tcp", addr)
+		}
+		resolved, err := cache.Resolve(addr)
+		if err != nil {
+			return nil, err
+		}
+		return net.DialTCP("tcp", nil, resolved)
+	}
+}
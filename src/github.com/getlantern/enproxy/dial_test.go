@@ -0,0 +1,177 @@
+package enproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialHappyEyeballsDialsASingleFamilyAddress(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	dialProxy := DialHappyEyeballs(0)
+	conn, err := dialProxy(ln.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = conn.Close()
+}
+
+// fakeDualStackDNS is a minimal UDP DNS server answering A and AAAA queries
+// for a single fixed hostname with fixed, fake addresses, so tests can
+// exercise Happy Eyeballs racing between address families without relying
+// on real dual-stack DNS or network connectivity.
+type fakeDualStackDNS struct {
+	conn *net.UDPConn
+	v4   net.IP
+	v6   net.IP
+}
+
+func startFakeDualStackDNS(t *testing.T, v4, v6 net.IP) *fakeDualStackDNS {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := &fakeDualStackDNS{conn: conn, v4: v4, v6: v6}
+	go s.serve()
+	return s
+}
+
+func (s *fakeDualStackDNS) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		resp := s.respond(buf[:n])
+		if resp != nil {
+			_, _ = s.conn.WriteToUDP(resp, addr)
+		}
+	}
+}
+
+// respond builds a reply to the single question in query, answering with
+// s.v4 for an A query or s.v6 for an AAAA query. It returns nil if query
+// doesn't look like a well-formed single-question DNS query.
+func (s *fakeDualStackDNS) respond(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	pos := 12
+	for pos < len(query) && query[pos] != 0 {
+		pos += int(query[pos]) + 1
+	}
+	if pos+5 > len(query) {
+		return nil
+	}
+	nameEnd := pos + 1
+	qtype := binary.BigEndian.Uint16(query[nameEnd : nameEnd+2])
+
+	var rdata []byte
+	switch qtype {
+	case 1: // A
+		rdata = s.v4.To4()
+	case 28: // AAAA
+		rdata = s.v6.To16()
+	default:
+		rdata = nil
+	}
+
+	resp := make([]byte, 12)
+	copy(resp, query[:2]) // transaction ID
+	resp[2], resp[3] = 0x81, 0x80
+	binary.BigEndian.PutUint16(resp[4:6], 1) // QDCOUNT
+	if rdata != nil {
+		binary.BigEndian.PutUint16(resp[6:8], 1) // ANCOUNT
+	}
+	resp = append(resp, query[12:nameEnd+4]...) // question section, verbatim
+	if rdata != nil {
+		resp = append(resp, 0xC0, 0x0C)                       // name: pointer to question
+		resp = append(resp, query[nameEnd], query[nameEnd+1]) // type
+		resp = append(resp, 0x00, 0x01)                       // class IN
+		resp = append(resp, 0x00, 0x00, 0x00, 0x01)           // TTL
+		rdlength := make([]byte, 2)
+		binary.BigEndian.PutUint16(rdlength, uint16(len(rdata)))
+		resp = append(resp, rdlength...)
+		resp = append(resp, rdata...)
+	}
+	return resp
+}
+
+func (s *fakeDualStackDNS) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *fakeDualStackDNS) close() {
+	_ = s.conn.Close()
+}
+
+// TestDialHappyEyeballsFallsBackWhenOneFamilyFails sets up a fake hostname
+// that resolves to an IPv4 address nothing is listening on and an IPv6
+// address that is, then confirms DialHappyEyeballs still connects promptly
+// by racing the two families rather than waiting out a full dial timeout
+// on the dead one.
+func TestDialHappyEyeballsFallsBackWhenOneFamilyFails(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available in this environment: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	_, portStr, err := net.SplitHostPort(ln.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	dns := startFakeDualStackDNS(t, net.ParseIP("127.0.0.1"), net.ParseIP("::1"))
+	defer dns.close()
+
+	// DialHappyEyeballs resolves through net.DefaultResolver, so point it at
+	// our fake DNS server for the duration of this test in order to
+	// control which addresses the race sees, restoring it afterward.
+	previousResolver := net.DefaultResolver
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return net.Dial("udp", dns.addr())
+		},
+	}
+	defer func() { net.DefaultResolver = previousResolver }()
+
+	dialProxy := DialHappyEyeballs(20 * time.Millisecond)
+
+	start := time.Now()
+	conn, err := dialProxy("happyeyeballs.test.enproxy.invalid:" + portStr)
+	elapsed := time.Since(start)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer conn.Close()
+
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "::1", host)
+	}
+	assert.True(t, elapsed < time.Second, "expected a prompt fallback, took %s", elapsed)
+}
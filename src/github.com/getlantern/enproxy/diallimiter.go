@@ -0,0 +1,92 @@
+package enproxy
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DialQueueTimeout is returned when a dial queued behind a DialLimiter
+// waits longer than its configured timeout for a free slot.
+type DialQueueTimeout struct {
+	// Waited is how long the dial waited before giving up.
+	Waited time.Duration
+}
+
+func (e *DialQueueTimeout) Error() string {
+	return fmt.Sprintf("dial queued for %s without a free slot", e.Waited)
+}
+
+// DialLimiter bounds how many dials to the proxy may be in flight at once
+// across every Conn sharing it via Config.DialLimiter, smoothing a burst
+// of simultaneous Connects (for example after a network change reconnects
+// many tunnels at once) into a steady trickle rather than a goroutine and
+// socket pileup. A dial beyond the limit queues rather than failing
+// outright, but gives up with a *DialQueueTimeout if it's still queued
+// after queueTimeout. Zero queueTimeout waits indefinitely. Safe for
+// concurrent use.
+type DialLimiter struct {
+	sem          chan struct{}
+	queueTimeout time.Duration
+
+	queued        int32
+	onQueueChange func(depth int)
+}
+
+// NewDialLimiter creates a DialLimiter allowing up to max concurrent
+// dials, queuing any beyond that for up to queueTimeout.
+func NewDialLimiter(max int, queueTimeout time.Duration) *DialLimiter {
+	return &DialLimiter{sem: make(chan struct{}, max), queueTimeout: queueTimeout}
+}
+
+// OnQueueChange registers a callback fired with the current queue depth
+// every time a dial starts or stops queuing, for exporting as a metric.
+// It's a no-op to leave this unset.
+func (l *DialLimiter) OnQueueChange(f func(depth int)) {
+	l.onQueueChange = f
+}
+
+// QueueDepth reports how many dials are currently queued waiting for a
+// free slot.
+func (l *DialLimiter) QueueDepth() int {
+	return int(atomic.LoadInt32(&l.queued))
+}
+
+// acquire reserves a slot, queuing (and counting towards QueueDepth) if
+// none is immediately free, and returning a *DialQueueTimeout if none
+// frees up within l.queueTimeout.
+func (l *DialLimiter) acquire() error {
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.adjustQueueDepth(1)
+	defer l.adjustQueueDepth(-1)
+
+	var timeoutCh <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-timeoutCh:
+		return &DialQueueTimeout{Waited: l.queueTimeout}
+	}
+}
+
+// release frees up a slot reserved by acquire.
+func (l *DialLimiter) release() {
+	<-l.sem
+}
+
+func (l *DialLimiter) adjustQueueDepth(delta int32) {
+	depth := atomic.AddInt32(&l.queued, delta)
+	if l.onQueueChange != nil {
+		l.onQueueChange(int(depth))
+	}
+}
@@ -0,0 +1,160 @@
+package enproxy
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialLimiterTimesOutWhenSlotNeverFrees(t *testing.T) {
+	limiter := NewDialLimiter(1, 20*time.Millisecond)
+
+	dialing := make(chan struct{}, 10)
+	block := make(chan struct{})
+	cfg := testConfig()
+	cfg.DialLimiter = limiter
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialing <- struct{}{}
+		<-block
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	first := cfg.NewConn("dest.example.com:80")
+	firstDone := make(chan error, 1)
+	go func() {
+		resp, err := first.doRequest(opConnect, nil)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		firstDone <- err
+	}()
+	<-dialing
+
+	second := cfg.NewConn("dest.example.com:80")
+	_, err := second.doRequest(opConnect, nil)
+	_, ok := err.(*DialQueueTimeout)
+	assert.True(t, ok, "expected a *DialQueueTimeout, got %T: %v", err, err)
+
+	close(block)
+	assert.NoError(t, <-firstDone)
+	_ = first.Close()
+}
+
+func TestDialLimiterQueuesThenSucceedsWhenSlotFrees(t *testing.T) {
+	limiter := NewDialLimiter(1, time.Second)
+
+	dialing := make(chan struct{}, 10)
+	block := make(chan struct{})
+	cfg := testConfig()
+	cfg.DialLimiter = limiter
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialing <- struct{}{}
+		<-block
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	first := cfg.NewConn("dest.example.com:80")
+	firstDone := make(chan error, 1)
+	go func() {
+		resp, err := first.doRequest(opConnect, nil)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		firstDone <- err
+	}()
+	<-dialing
+
+	second := cfg.NewConn("dest.example.com:80")
+	secondDone := make(chan error, 1)
+	go func() {
+		resp, err := second.doRequest(opConnect, nil)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		secondDone <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-secondDone:
+		t.Fatal("second Connect should still be queued behind the dial limit")
+	default:
+	}
+
+	close(block)
+
+	assert.NoError(t, <-firstDone)
+	defer func() { _ = first.Close() }()
+
+	select {
+	case err := <-secondDone:
+		assert.NoError(t, err)
+		_ = second.Close()
+	case <-time.After(time.Second):
+		t.Fatal("second Connect should have dialed once the first released its slot")
+	}
+}
+
+func TestDialLimiterReportsQueueDepth(t *testing.T) {
+	limiter := NewDialLimiter(1, time.Second)
+
+	var mu sync.Mutex
+	var depths []int
+	limiter.OnQueueChange(func(depth int) {
+		mu.Lock()
+		depths = append(depths, depth)
+		mu.Unlock()
+	})
+
+	dialing := make(chan struct{}, 10)
+	block := make(chan struct{})
+	cfg := testConfig()
+	cfg.DialLimiter = limiter
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialing <- struct{}{}
+		<-block
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	first := cfg.NewConn("dest.example.com:80")
+	firstDone := make(chan error, 1)
+	go func() {
+		resp, err := first.doRequest(opConnect, nil)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		firstDone <- err
+	}()
+	<-dialing
+
+	second := cfg.NewConn("dest.example.com:80")
+	secondDone := make(chan error, 1)
+	go func() {
+		resp, err := second.doRequest(opConnect, nil)
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		secondDone <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(block)
+	assert.NoError(t, <-firstDone)
+	defer func() { _ = first.Close() }()
+	assert.NoError(t, <-secondDone)
+	defer func() { _ = second.Close() }()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, depths, 1, "queue depth should have been reported as 1 while the second dial was queued")
+	assert.Equal(t, 0, limiter.QueueDepth())
+}
@@ -0,0 +1,88 @@
+package enproxy
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// negativeCacheTTLFraction bounds how long a failed lookup is cached,
+// relative to the configured TTL, so that a struggling resolver is retried
+// soon without hammering it on every dial.
+const negativeCacheTTLFraction = 10
+
+type dnsCacheEntry struct {
+	addr    *net.TCPAddr
+	err     error
+	expires time.Time
+}
+
+// DNSCache caches the results of resolving proxy addresses, so that
+// high-dial-rate clients hitting the same hostnames repeatedly don't pay for
+// redundant resolution. It's safe for concurrent use and is shared across
+// Conns by assigning it to a Config, or used directly via DialTCP.
+//
+// The zero value is not usable; construct one with NewDNSCache.
+type DNSCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]dnsCacheEntry
+	order   []string
+}
+
+// NewDNSCache creates a DNSCache holding up to size entries, each valid for
+// ttl. Failed lookups are cached too, but for a much shorter time, so that a
+// transient resolution failure doesn't get "stuck" for a full ttl.
+func NewDNSCache(size int, ttl time.Duration) *DNSCache {
+	return &DNSCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]dnsCacheEntry),
+	}
+}
+
+// Resolve returns the resolved *net.TCPAddr for addr, serving it from cache
+// if a still-valid entry exists and resolving (and caching the result,
+// success or failure) otherwise.
+func (c *DNSCache) Resolve(addr string) (*net.TCPAddr, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if entry, found := c.entries[addr]; found && now.Before(entry.expires) {
+		c.mu.Unlock()
+		return entry.addr, entry.err
+	}
+	c.mu.Unlock()
+
+	resolved, err := net.ResolveTCPAddr("tcp", addr)
+
+	ttl := c.ttl
+	if err != nil {
+		ttl = c.ttl / negativeCacheTTLFraction
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.entries[addr]; !found {
+		c.order = append(c.order, addr)
+		if c.size > 0 && len(c.order) > c.size {
+			oldest := c.order[0]
+			c.order = c.order[1:]
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[addr] = dnsCacheEntry{addr: resolved, err: err, expires: now.Add(ttl)}
+
+	return resolved, err
+}
+
+// Flush discards all cached entries, forcing the next Resolve for any
+// address to hit the resolver again.
+func (c *DNSCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]dnsCacheEntry)
+	c.order = nil
+}
@@ -0,0 +1,55 @@
+package enproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSCacheServesFromCache(t *testing.T) {
+	cache := NewDNSCache(10, time.Minute)
+
+	first, err := cache.Resolve("localhost:80")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	second, err := cache.Resolve("localhost:80")
+	assert.NoError(t, err)
+	assert.True(t, first == second, "second Resolve should be served from cache, not re-resolved")
+}
+
+func TestDNSCacheFlush(t *testing.T) {
+	cache := NewDNSCache(10, time.Minute)
+
+	first, _ := cache.Resolve("localhost:80")
+	cache.Flush()
+	second, _ := cache.Resolve("localhost:80")
+
+	assert.True(t, first != second, "Flush should force re-resolution")
+}
+
+func TestDNSCacheNegativeCaching(t *testing.T) {
+	cache := NewDNSCache(10, 100*time.Millisecond)
+
+	_, err := cache.Resolve("not a valid address")
+	assert.Error(t, err)
+
+	entry, found := cache.entries["not a valid address"]
+	if !assert.True(t, found) {
+		t.FailNow()
+	}
+	assert.True(t, entry.expires.Before(time.Now().Add(100*time.Millisecond)),
+		"negative cache entry should expire much sooner than a positive TTL")
+}
+
+func TestDNSCacheEvictsOldestOnceFull(t *testing.T) {
+	cache := NewDNSCache(1, time.Minute)
+
+	cache.Resolve("a.example.com:80")
+	cache.Resolve("b.example.com:80")
+
+	_, found := cache.entries["a.example.com:80"]
+	assert.False(t, found, "oldest entry should have been evicted once size was exceeded")
+}
@@ -0,0 +1,24 @@
+package enproxy
+
+import (
+	"io"
+	"net/http"
+)
+
+// maxDrainBytes bounds how much of a non-2xx response body drainAndClose will
+// read before giving up and closing the connection anyway, so a
+// misbehaving or malicious proxy can't stall a redial by streaming an
+// unbounded error body.
+const maxDrainBytes = 64 * 1024
+
+// drainAndClose reads and discards up to maxDrainBytes of resp.Body before
+// closing it. net/http can only reuse the underlying connection for a
+// later request once a response body has been fully read; doRequest passes
+// the non-2xx responses it retries past (400 under AutoNegotiateEncoding,
+// 407, 429) through here rather than closing them unread, which would
+// otherwise force every such response to redial instead of reusing the
+// proxy connection.
+func drainAndClose(resp *http.Response) {
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+	_ = resp.Body.Close()
+}
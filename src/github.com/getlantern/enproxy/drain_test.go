@@ -0,0 +1,67 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rateLimitedThenOKProxy answers the first request on conn with a 429 that
+// carries a non-empty body, then answers every request after that with a
+// plain 200 OK, all on the same connection. If the 429's body isn't fully
+// drained before the next request is sent, the leftover bytes corrupt the
+// next response http.ReadResponse parses off the same reader.
+func rateLimitedThenOKProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	first := true
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+
+		if first {
+			first = false
+			body := "please slow down, too many requests right now"
+			resp := "HTTP/1.1 429 Too Many Requests\r\nContent-Length: " +
+				strconv.Itoa(len(body)) + "\r\n\r\n" + body
+			if _, err := conn.Write([]byte(resp)); err != nil {
+				return
+			}
+			continue
+		}
+
+		if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func TestNonOKResponseBodyIsDrainedSoConnectionStaysReusable(t *testing.T) {
+	var dialCount int32
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		atomic.AddInt32(&dialCount, 1)
+		client, server := net.Pipe()
+		go rateLimitedThenOKProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err, "the retried request should parse cleanly off the reused connection") {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dialCount), "the 429 should have been retried on the same proxy connection rather than redialing")
+}
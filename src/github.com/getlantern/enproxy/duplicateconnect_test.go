@@ -0,0 +1,62 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// idCapturingProxy answers every request on conn with a plain 200,
+// recording the XEnproxyID it saw on each one.
+func idCapturingProxy(conn net.Conn, idsCh chan<- string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		idsCh <- req.Header.Get(XEnproxyID)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+// TestRetriedConnectReusesSameID verifies the precondition a server needs
+// to dedupe a retried connect op (request #synth-232's idempotent connect
+// handling, which lives server-side and so isn't implemented in this
+// package): a Conn never changes its XEnproxyID across requests, including
+// repeated connect attempts against the same logical connection.
+func TestRetriedConnectReusesSameID(t *testing.T) {
+	idsCh := make(chan string, 2)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go idCapturingProxy(server, idsCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	firstID := <-idsCh
+
+	// Simulate a client-side retry of the connect op after a lost response.
+	resp, err = conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	secondID := <-idsCh
+
+	assert.Equal(t, firstID, secondID, "a retried connect should carry the same id so the server can recognize it as a duplicate")
+	assert.NotEmpty(t, firstID)
+}
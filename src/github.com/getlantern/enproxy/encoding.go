@@ -0,0 +1,26 @@
+package enproxy
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// encodingTroubleStatus is the status this package treats as a signal,
+// when Config.AutoNegotiateEncoding is set, that the proxy rejected the
+// request's transfer encoding. A proxy that's picky about identity versus
+// chunked has nothing more specific to report that with, so a generic bad
+// request is what's checked for rather than anything encoding-specific.
+const encodingTroubleStatus = http.StatusBadRequest
+
+// useChunkedEncoding reports whether c has already learned, from an
+// earlier request on this Conn, that the proxy wants chunked transfer
+// encoding instead of whatever Config.NewRequest produces by default.
+func (c *Conn) useChunkedEncoding() bool {
+	return atomic.LoadInt32(&c.chunkedEncoding) == 1
+}
+
+// preferChunkedEncoding remembers, for the rest of c's requests, that the
+// proxy wants chunked transfer encoding.
+func (c *Conn) preferChunkedEncoding() {
+	atomic.StoreInt32(&c.chunkedEncoding, 1)
+}
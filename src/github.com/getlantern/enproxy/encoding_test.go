@@ -0,0 +1,85 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rejectsNonChunkedProxy answers a request with 400 Bad Request unless its
+// TransferEncoding is chunked, in which case it answers 200 OK, simulating
+// a proxy that's picky about encoding the way Config.AutoNegotiateEncoding
+// is meant to work around.
+func rejectsNonChunkedProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		chunked := len(req.TransferEncoding) > 0 && req.TransferEncoding[0] == "chunked"
+		if chunked {
+			_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+		} else {
+			_, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n"))
+		}
+	}
+}
+
+// TestAutoNegotiateEncodingRetriesWithChunked confirms that a 400 from the
+// proxy triggers an automatic retry with chunked transfer encoding when
+// Config.AutoNegotiateEncoding is set, and that the preference sticks for
+// later requests on the same Conn.
+func TestAutoNegotiateEncodingRetriesWithChunked(t *testing.T) {
+	cfg := testConfig()
+	cfg.AutoNegotiateEncoding = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go rejectsNonChunkedProxy(server)
+		return client, nil
+	}
+
+	// Drive this through doRequest directly rather than Connect/Write, so
+	// the background read-polling loop Connect starts doesn't also race
+	// requests onto the same net.Pipe concurrently with the one below.
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.True(t, conn.useChunkedEncoding(), "the connect op's 400 should have flipped the Conn to chunked encoding")
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+}
+
+// TestWithoutAutoNegotiateEncodingA400IsJustAFailure confirms the 400 isn't
+// specially handled at all without Config.AutoNegotiateEncoding.
+func TestWithoutAutoNegotiateEncodingA400IsJustAFailure(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go rejectsNonChunkedProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.False(t, conn.useChunkedEncoding())
+}
@@ -0,0 +1,722 @@
+// Package enproxy provides a net.Conn-compatible interface for tunneling a
+// connection to a destination through a proxy that does not support CONNECT,
+// by encoding writes and reads as a series of HTTP requests and responses.
+//
+// A logical connection is identified by an id that is included as a header
+// on every request so that the proxy can correlate requests belonging to the
+// same tunnel. Connection lifecycle is driven by an "op" header indicating
+// whether the request is establishing the connection, writing data, reading
+// data or tearing the connection down.
+//
+// This package implements only the client side of that protocol. Anything
+// that happens on the proxy's connection to the destination -- socket
+// options like TCP_NODELAY, buffering, backpressure -- is configured on the
+// proxy implementation and out of scope here; the client has no handle on
+// that socket at all. The same is true of operational concerns that live
+// entirely on the proxy, like rate limiting (see RateLimited) and exposing
+// a health-check endpoint for a load balancer: there's no Proxy or server
+// type in this package to hang either of those off of.
+package enproxy
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/getlantern/golog"
+)
+
+const (
+	// XEnproxyID identifies the logical connection that a request belongs
+	// to. It's generated once in NewConn and reused for every request a
+	// Conn ever sends, including a retried connect op, so a server that
+	// wants to dedupe a retried connect against one it already handled
+	// (because the client's first response never arrived) can key on it;
+	// doRequest retries a connect op after a transport failure only when
+	// Config.ShouldRetry allows it (AutoReconnect covers only read and
+	// write ops), and every such retry carries this same id. It's generated
+	// with uuid.New and isn't itself configurable; detecting and handling
+	// two different Conns colliding on this id (which, short of a bug
+	// elsewhere, would mean the same id reaching the proxy for two
+	// different destinations) is entirely a server-side concern, since
+	// this client-only package never sees another Conn's id to compare
+	// against. The same goes for validating this id's format or provenance
+	// (for example an HMAC-signed id scheme a closed fleet's proxy might
+	// require) before trusting a request: there's no hook here to
+	// generate anything other than a plain uuid.New value, and no server
+	// side in this package to reject one that fails such a check.
+	XEnproxyID = "X-Enproxy-Id"
+
+	// XEnproxyReqID identifies a single request, distinct from XEnproxyID's
+	// whole-connection scope. Unlike XEnproxyID it's generated fresh for
+	// every call to doRequest (so every retry of the same logical op, op
+	// wise, shares one, but a later op gets a new one), letting a slow or
+	// misbehaving individual request be traced across client and server
+	// logs without conflating it with every other request the Conn has
+	// ever sent. See Config.OnResponseHeaders.
+	XEnproxyReqID = "X-Enproxy-Req-Id"
+
+	// XEnproxyDestAddr carries the address that the proxy should connect to
+	// on behalf of the client. This package always sets it to Conn's real
+	// destination, never a placeholder; a proxy that falls back to SNI
+	// extraction when it's absent (for transparently tunneling raw TLS
+	// without a client that pre-specifies the destination) is a
+	// server-side concern this package doesn't implement or need to.
+	XEnproxyDestAddr = "X-Enproxy-Dest-Addr"
+
+	// XEnproxyOp identifies what operation a request is performing against
+	// the connection (see the opXXX constants).
+	XEnproxyOp = "X-Enproxy-Op"
+
+	opConnect = "connect"
+	opWrite   = "write"
+	opRead    = "read"
+	opClose   = "close"
+
+	// opPing is a minimal, body-less op used only to validate a cached
+	// proxy connection is still alive before reusing it; see
+	// Config.ValidateBeforeReuse. A proxy that doesn't recognize it is
+	// expected to respond with some non-2xx status (or close the
+	// connection outright), either of which validation treats the same as
+	// any other dead connection: redial rather than fail the request.
+	opPing = "ping"
+
+	// OpConnect, OpWrite, OpRead and OpClose are exported aliases of the op
+	// values above, for keying Config.OpHeaders.
+	OpConnect = opConnect
+	OpWrite   = opWrite
+	OpRead    = opRead
+	OpClose   = opClose
+
+	// XEnproxyEOF, when set to "true" on a read response, tells the client
+	// that the destination has closed and no further reads should be
+	// issued once this response's body has been fully consumed. See
+	// Config.StrictEOF for how a body that arrives anyway is handled.
+	XEnproxyEOF = "X-Enproxy-Eof"
+
+	// XEnproxyStrategy carries the request strategy the proxy has settled on
+	// for a connection, in the response to a connect op.
+	XEnproxyStrategy = "X-Enproxy-Strategy"
+
+	// XEnproxyWriteSeq carries a per-connection, 1-based, strictly
+	// increasing sequence number on every write op, assigned in the order
+	// Write was called rather than the order requests happen to arrive at
+	// the proxy. Parallel writes (see Config.MaxConcurrentWrites) and
+	// retries can otherwise reach the destination out of order; a proxy
+	// that cares about ordering can reassemble the original order from
+	// this header before writing to the destination. This package only
+	// produces the header; reordering is a server-side concern it doesn't
+	// implement.
+	XEnproxyWriteSeq = "X-Enproxy-Write-Seq"
+
+	// strategyPolling is the only request strategy this package currently
+	// implements: reads are serviced by repeatedly polling the proxy.
+	strategyPolling = "polling"
+
+	// XEnproxyOptimisticReadWindow, on a connect request, asks the proxy
+	// to wait for up to this many bytes from the destination (or its own
+	// short internal timeout, whichever comes first) and return whatever
+	// it has as the connect response body, rather than the empty body a
+	// connect op otherwise has. See Config.OptimisticReadWindow.
+	XEnproxyOptimisticReadWindow = "X-Enproxy-Optimistic-Read-Window"
+
+	// XEnproxyReadBatchMaxBytes, on a read request, asks the proxy to
+	// accumulate up to this many bytes of destination data (across
+	// however many frames it takes) before responding, instead of
+	// returning as soon as it has anything. See Config.ReadBatchMaxBytes.
+	XEnproxyReadBatchMaxBytes = "X-Enproxy-Read-Batch-Max-Bytes"
+
+	// XEnproxyReadBatchMaxWait, on a read request, bounds how long the
+	// proxy should hold the request open accumulating data toward
+	// XEnproxyReadBatchMaxBytes before responding with whatever it has,
+	// expressed in milliseconds. See Config.ReadBatchMaxWait.
+	XEnproxyReadBatchMaxWait = "X-Enproxy-Read-Batch-Max-Wait"
+
+	// XEnproxyWriteAckMode, on a write request, carries Config.WriteAckMode
+	// verbatim when it's WriteAckDestWritten (no other mode sends the
+	// header), so a proxy that implements stronger acknowledgment
+	// semantics than simply accepting the request knows which one this
+	// write wants. This package only sends the header; a proxy that
+	// doesn't recognize it just acknowledges receipt as it always has,
+	// which is indistinguishable from WriteAckProxyReceived.
+	XEnproxyWriteAckMode = "X-Enproxy-Write-Ack-Mode"
+
+	// WriteAckFireAndForget, WriteAckProxyReceived and WriteAckDestWritten
+	// are the values Config.WriteAckMode accepts. See its doc comment.
+	WriteAckFireAndForget = "fire-and-forget"
+	WriteAckProxyReceived = "proxy-received"
+	WriteAckDestWritten   = "dest-written"
+)
+
+var (
+	log = golog.LoggerFor("enproxy")
+)
+
+// Config configures how Conns dial and communicate with the proxy.
+type Config struct {
+	// DialProxy dials the proxy itself. It is called once per underlying
+	// connection to the proxy (new connections are created as needed, for
+	// example after a redial).
+	DialProxy func(addr string) (net.Conn, error)
+
+	// ConnectTimeout bounds the entire tunnel establishment Dial performs
+	// -- dialing the proxy, the TLS handshake if TLS is set, and the
+	// connect op -- as a single overall budget, rather than each stage
+	// having to be bounded separately. Zero uses a 30 second default; to
+	// wait indefinitely, set it to a very large duration instead. It has
+	// no effect on a Conn connected via Conn.Connect directly rather than
+	// through Dial.
+	ConnectTimeout time.Duration
+
+	// WrapProxyConn, if set, wraps every freshly dialed proxy connection
+	// before it's used, whether from an ordinary DialProxy call or a
+	// redial, letting callers inject instrumentation (byte counting,
+	// latency measurement, tcpdump-style capture) at the socket level. It
+	// runs after linger/buffer-size socket options are applied (which
+	// need the real *net.TCPConn to work) but before TLS, so it sees the
+	// actual bytes on the wire, including the TLS handshake, rather than
+	// the plaintext TLS would otherwise decrypt down to.
+	WrapProxyConn func(net.Conn) net.Conn
+
+	// NewRequest constructs an *http.Request for sending to the proxy.
+	// upstreamHost is the Host header to use (the proxy's own host); method
+	// and body are supplied by Conn depending on what operation is being
+	// performed.
+	NewRequest func(upstreamHost string, method string, body io.Reader) (*http.Request, error)
+
+	// Host is the address (host:port) of the proxy, used as the Host header
+	// on outgoing requests when NewRequest doesn't already set one.
+	Host string
+
+	// TLS indicates that connections to the proxy should be wrapped in TLS
+	// immediately after dialing.
+	TLS bool
+
+	// ClientCert, if set, is presented to the proxy as a client certificate
+	// so that it can mutually authenticate the client. Requires TLS to be
+	// set.
+	ClientCert *tls.Certificate
+
+	// MinTLSVersion sets the floor (e.g. tls.VersionTLS12) below which the
+	// handshake with the proxy refuses to negotiate, so a compromised or
+	// misconfigured edge can't downgrade the hop to a weak protocol
+	// version. Defaults to tls.VersionTLS12 when unset. Has no effect
+	// unless TLS is set.
+	MinTLSVersion uint16
+
+	// PayloadKey, if set, AES-GCM encrypts every request and response body
+	// end-to-end between this Conn and the real backend, independent of
+	// (and on top of) whatever transport security TLS provides. This is
+	// defense-in-depth for proxies that terminate TLS at an untrusted edge
+	// (for example a CDN) in front of a trusted backend: the edge sees only
+	// ciphertext, and a backend without the key can't decrypt or tamper
+	// with it undetected. Must be a valid AES-128/192/256 key (16, 24 or 32
+	// bytes) and must match the key the backend decrypts with; there's no
+	// key negotiation. Has no effect once CompactFraming has taken over
+	// framing, since that's a different wire format this doesn't cover.
+	PayloadKey []byte
+
+	// OnStrategyChange, if set, is called whenever a Conn's request strategy
+	// changes, including once after the initial strategy is settled during
+	// Connect. It's a no-op to leave this nil.
+	OnStrategyChange func(from, to string)
+
+	// MaxBytesPerSecond caps combined read and write throughput per Conn
+	// using a token bucket, smoothing bursts rather than chunking them. Zero
+	// means unlimited. MaxReadBytesPerSecond and MaxWriteBytesPerSecond, if
+	// set, override this for their respective direction.
+	MaxBytesPerSecond int64
+
+	// MaxReadBytesPerSecond caps read throughput. Zero falls back to
+	// MaxBytesPerSecond.
+	MaxReadBytesPerSecond int64
+
+	// MaxWriteBytesPerSecond caps write throughput. Zero falls back to
+	// MaxBytesPerSecond.
+	MaxWriteBytesPerSecond int64
+
+	// RequestInterceptor, if set, is called in doRequest just before
+	// req.Write, after the XEnproxyID/XEnproxyDestAddr/XEnproxyOp headers
+	// have already been set on req. It is a general extension point for
+	// things like tracing headers, signing or body mutation, and runs last,
+	// after any other header-setting logic, so it sees (and can still
+	// rewrite) exactly what's about to go on the wire. Returning an error
+	// aborts the request.
+	RequestInterceptor func(req *http.Request) error
+
+	// OnResponseHeaders, if set, is called with a request's XEnproxyReqID
+	// and the headers of its response, each time doRequestOnce succeeds in
+	// getting one back from the proxy. This is the hook for correlating a
+	// specific slow or erroring request with whatever the proxy logged
+	// against that same id, rather than just the connection as a whole. It
+	// doesn't run for a request that fails outright with no response (a
+	// dial or write failure); those are visible through Config.ShouldRetry
+	// and Conn.ErrorCounts instead.
+	OnResponseHeaders func(op string, reqID string, header http.Header)
+
+	// ForwardResponseHeaders names response headers (from the proxy's
+	// responses, which may themselves be relaying headers the destination
+	// set) to collect and expose via Conn.ForwardedHeaders, so an app
+	// tunneling HTTP over this Conn can get at destination metadata (e.g. a
+	// request id or cache status) without this package exposing every
+	// header indiscriminately. Defaults to forwarding none. Be deliberate
+	// about what's named here: a proxy-internal header leaking through
+	// (session tokens, internal routing hints) is exposed to the app the
+	// same as any destination header would be.
+	ForwardResponseHeaders []string
+
+	// MaxRateLimitBackoff bounds how long a single request will cumulatively
+	// sleep in response to 429s with a Retry-After header before giving up
+	// and returning a *RateLimited error. Zero uses a 30 second default.
+	MaxRateLimitBackoff time.Duration
+
+	// OnRateLimited, if set, is called every time the proxy responds 429,
+	// with the backoff that's about to be observed before retrying.
+	OnRateLimited func(backoff time.Duration)
+
+	// Backoff supplies the delay doRequest falls back to when a 429
+	// response doesn't carry a usable Retry-After header. Nil uses a
+	// shared *ExponentialBackoff. Reset is called once per doRequest call
+	// before any retries begin, so a stateful Backoff (like
+	// *DecorrelatedJitterBackoff) still starts each request's retry
+	// sequence cold even if the same instance is reused across Conns.
+	Backoff Backoff
+
+	// ShouldRetry, if set, decides whether doRequest retries a failed
+	// attempt at the transport level -- a dialing, write or read failure
+	// from doRequestOnce that isn't one of the status-code-driven retries
+	// (407/429) those already handle on their own. It's called with op,
+	// the 1-based count of attempts made so far (including the one that
+	// just failed), the error doRequestOnce returned (nil if the request
+	// succeeded but resp is being offered for inspection instead -- this
+	// doesn't currently happen, but the parameter is there for symmetry),
+	// and the response if one was received. This lets a caller encode
+	// logic the built-in retrying can't, like "retry reads but not writes,
+	// since a write may have been partially delivered before the failure.
+	// Returning true retries, after the same Config.Backoff delay the
+	// 429 path uses. Nil leaves the decision to Config.AutoReconnect; with
+	// that unset too, no such retries happen.
+	ShouldRetry func(op string, attempt int, err error, resp *http.Response) bool
+
+	// AutoReconnect, if set, makes doRequest's transport-error retry path
+	// (see ShouldRetry) default to redialing and retrying opRead/opWrite
+	// up to MaxReconnectAttempts times on its own, so a transient failure
+	// against the proxy doesn't surface to the app as a Read/Write error
+	// -- the caller only sees one once that budget runs out, as a
+	// *ReconnectExhausted, at which point the Conn closes with
+	// ReasonRedialExhausted. An explicit ShouldRetry always takes
+	// precedence over this default, exactly as if AutoReconnect weren't
+	// set. This makes no data-loss guarantees: a retried write carries
+	// the same XEnproxyWriteSeq as the attempt before it, which lets a
+	// proxy that tracks sequence numbers recognize and skip a duplicate,
+	// but whether it actually does so is proxy-dependent; and any
+	// destination data the proxy had already accepted for a read but not
+	// yet gotten back to us when the connection dropped is simply gone.
+	// AutoReconnect only guarantees the client transparently keeps
+	// polling through a transient proxy-connection failure, not that no
+	// bytes were lost crossing it.
+	AutoReconnect bool
+
+	// MaxReconnectAttempts bounds how many times AutoReconnect will
+	// redial and retry a single Read or Write call. Zero uses a default
+	// of 5. Has no effect unless AutoReconnect is set.
+	MaxReconnectAttempts int
+
+	// OnRedial, if set, is called every time doRequest redials and retries
+	// after a transport-level failure (see ShouldRetry/AutoReconnect),
+	// with a RedialEvent describing it, so a caller can drive a live
+	// "reconnecting..." indicator instead of only learning about
+	// instability after the fact from Conn.Stats. It's called
+	// synchronously from doRequest's retry loop, so it must not block;
+	// do any slow work (updating a UI, logging) on a separate goroutine.
+	OnRedial func(RedialEvent)
+
+	// AuthHandler, if set, is called whenever the proxy responds 407 with
+	// the Proxy-Authenticate header's value, and should return the
+	// Proxy-Authorization value to retry with. It may be called more than
+	// once per request for multi-round schemes (NTLM, Negotiate), each
+	// time with that round's challenge; doRequest gives up and returns a
+	// *AuthFailed after MaxAuthRounds rounds. Nil leaves 407s unhandled,
+	// so they're returned to the caller like any other response.
+	AuthHandler func(challenge string) (credentials string, err error)
+
+	// MaxAuthRounds bounds how many rounds of AuthHandler a single
+	// request will go through on repeated 407s. Zero uses a default of 5.
+	MaxAuthRounds int
+
+	// UserAgent, if set, is sent as the User-Agent header on every
+	// request, overriding UserAgents.
+	UserAgent string
+
+	// UserAgents, if set and UserAgent is empty, is rotated through in
+	// round-robin order, one pick per logical request (every retry of
+	// that request reuses the same pick, so it always looks like one
+	// consistent client). Neither set falls back to a generic
+	// browser-like default rather than the standard library's own.
+	UserAgents []string
+
+	// MaxBufferAge is WriteCoalesceWindow's old name, kept so existing
+	// callers that only set this still compile and behave the same way.
+	// Prefer WriteCoalesceWindow in new code; if both are set,
+	// WriteCoalesceWindow wins.
+	MaxBufferAge time.Duration
+
+	// WriteCoalesceWindow bounds how long a byte written via Write may
+	// wait before being sent to the proxy. Write currently issues a
+	// request for every call immediately, so no byte is ever buffered and
+	// this bound is trivially satisfied regardless of its value; it's
+	// here so that if this package grows a write-coalescing strategy
+	// (batching several Write calls into one request to cut per-request
+	// overhead), that strategy has an independently enforced worst-case
+	// latency to respect rather than relying solely on its own
+	// flush-trigger logic, which can have edge cases. Zero means no such
+	// bound is enforced; for a bulk-transfer profile that favors fewer,
+	// larger requests over latency, a window of tens of milliseconds
+	// would be reasonable once coalescing exists to make use of it.
+	WriteCoalesceWindow time.Duration
+
+	// ReadCoalesceWindow is WriteCoalesceWindow's read-side counterpart:
+	// how long processReads may wait to accumulate more destination data
+	// before delivering what it already has to Read, once coalescing is
+	// implemented on that side. processReads currently streams each read
+	// op's response to Read as it arrives rather than batching across
+	// read ops, so this bound is likewise trivially satisfied today. A
+	// download-heavy workload would want this set aggressively (hundreds
+	// of milliseconds) to cut per-read-op overhead; an interactive one
+	// (a remote shell) would want it left at zero so output isn't
+	// delayed waiting to coalesce with more.
+	ReadCoalesceWindow time.Duration
+
+	// ResponseBodyTransform, if set, wraps each read op's response body
+	// before processReads streams it to Read, for example to verify an
+	// integrity checksum or decrypt an additional layer the proxy applied
+	// on top of whatever this package itself already decoded (such as
+	// StreamCompression's decompression, which runs first). op is always
+	// OpRead. Nil applies no transform, at no overhead.
+	ResponseBodyTransform func(op string, r io.Reader) io.Reader
+
+	// OmitContentTypeOnEmptyBody strips any Content-Type header NewRequest
+	// set whenever a request has no body (connect, read and close ops
+	// always qualify; write never does). Some middleboxes treat a
+	// Content-Type on a bodyless POST as a fingerprinting signal, so this
+	// is off by default to preserve whatever NewRequest already does.
+	OmitContentTypeOnEmptyBody bool
+
+	// AutoNegotiateEncoding, if set, makes doRequest retry a request that
+	// the proxy rejected (a generic 400, since that's the only signal
+	// most proxies have for this) with chunked transfer encoding forced
+	// on, instead of whatever Config.NewRequest's request produces by
+	// default. Once the proxy has rejected a request like that, the Conn
+	// remembers the preference and every later request on it goes straight to chunked,
+	// rather than re-discovering the same rejection every time. This
+	// doesn't detect or recover from the reverse case (a proxy that
+	// rejects chunked and wants identity), since Config.NewRequest's
+	// default already isn't chunked.
+	AutoNegotiateEncoding bool
+
+	// Metrics, if set, is notified of a Conn's request lifecycle, redials,
+	// connection establishment and closing through a single interface,
+	// for an app that would rather implement one cohesive Metrics than
+	// wire up OnRedial/OnClose/etc individually. Both styles can be used
+	// together; see Metrics's doc comment. Nil means no such notification
+	// happens, at no overhead beyond a nil check per event.
+	Metrics Metrics
+
+	// Chaos, if set, injects artificial latency, dropped requests and
+	// corrupted responses into the request path, for deterministically
+	// testing a caller's own error handling and retry logic over the
+	// tunnel. Nil (the default) applies none of that; see ChaosConfig for
+	// why this is safe to leave nil in production.
+	Chaos *ChaosConfig
+
+	// CompactFraming requests that, once the connect handshake succeeds
+	// over regular HTTP, the proxy switch this connection to a minimal
+	// binary framing for all subsequent ops. The proxy must support and
+	// agree to the upgrade (signaled via a 101 response); if it doesn't,
+	// the Conn silently continues using HTTP framing. Only useful when the
+	// deployment controls both ends of the tunnel and wants near-raw-TCP
+	// overhead on a persistent connection.
+	CompactFraming bool
+
+	// RequireFeatures names optional features (see the FeatureXXX
+	// constants) that must actually be active once Connect's negotiation
+	// finishes, rather than being quietly downgraded away -- for example
+	// FeatureFraming, when a deployment depends on CompactFraming's lower
+	// overhead and would rather fail fast than silently fall back to
+	// regular HTTP framing against a proxy that doesn't support it.
+	// Connect returns a *RequiredFeatureUnavailable if any named feature
+	// isn't active, including features (compression, keepalive, resume)
+	// this package doesn't implement negotiating at all yet.
+	RequireFeatures []Feature
+
+	// CompactHeaders shortens the X-Enproxy-Id/Dest-Addr/Op headers sent
+	// with every ordinary HTTP-framed request to their compact forms and
+	// drops User-Agent and X-Enproxy-Req-Id entirely, for paths with a
+	// very small effective MTU (some VPNs, tunnel-in-tunnel setups) where
+	// large headers plus body can fragment badly. The proxy must
+	// recognize the compact header forms; this is independent of
+	// CompactFraming, which replaces HTTP framing altogether, for
+	// deployments that need to stay on ordinary HTTP framing but still
+	// want smaller requests.
+	CompactHeaders bool
+
+	// MaxReassemblyBytes bounds how large a single frame's payload length
+	// readCompactFrame will believe from the wire when CompactFraming is in
+	// use, so a hostile or buggy proxy can't make the client allocate
+	// gigabytes for a payload that will never actually arrive. This
+	// package doesn't otherwise do out-of-order or split-frame reassembly
+	// -- compact framing is strictly one request, one response, per op --
+	// so this is the one place a length prefix from the wire drives an
+	// allocation before the data backing it has been read. Zero uses a 16
+	// MiB default; has no effect when CompactFraming is off.
+	MaxReassemblyBytes int
+
+	// IdleTimeout, if set, closes (and causes a future redial of) the
+	// connection to the proxy after this much total inactivity. An empty
+	// long-poll response still counts as activity.
+	IdleTimeout time.Duration
+
+	// DisableIdleTiming overrides IdleTimeout, skipping the idle-timeout
+	// wrapper (and its background timer goroutine) entirely. Useful when a
+	// Config with a non-zero IdleTimeout is shared across Conns and one of
+	// them needs to opt out without mutating the shared Config.
+	DisableIdleTiming bool
+
+	// OnIdle, if set, is consulted once IdleTimeout has already closed the
+	// connection to the proxy, to decide what happens next: return
+	// IdleClose (the default when OnIdle is nil) to leave the closed
+	// connection as is, or IdleKeepalive to forget it so the next request
+	// transparently redials instead of failing against a dead connection.
+	// Custom logic -- e.g. only keeping alive while some app-level signal
+	// says the tunnel is still wanted -- can inspect c and return either
+	// constant. Note that redialing, unlike a proxy-level ping, still costs
+	// a fresh TCP/TLS handshake on the next request and keeps no traffic
+	// flowing while idle, so it doesn't hold a radio awake the way a true
+	// keepalive ping would; see IdleKeepalive's doc for how it interacts
+	// with Config.ShouldRetry.
+	OnIdle func(c *Conn) IdleAction
+
+	// StallTimeout, if set, bounds how long Read will wait for more data
+	// once a response has started arriving. Exceeding it returns a
+	// *Stalled error, distinct from an empty response (which just means
+	// the destination has nothing to send yet).
+	StallTimeout time.Duration
+
+	// UnreadDataTimeout, if set, bounds how long processReads will sit
+	// with a chunk of response data ready to deliver and no Read call
+	// around to take it, before applying UnreadDataPolicy. This package
+	// delivers chunks through an unbuffered channel, so an app that
+	// writes but never reads doesn't pile up memory -- it just leaves
+	// processReads parked forever waiting on a Read that isn't coming,
+	// which this catches. Zero disables it, preserving that indefinite
+	// wait, which is fine for apps that always eventually drain what they
+	// write.
+	UnreadDataTimeout time.Duration
+
+	// UnreadDataPolicy decides what happens when UnreadDataTimeout
+	// elapses; see UnreadDataClose and UnreadDataDrop. Has no effect
+	// unless UnreadDataTimeout is set. Defaults to UnreadDataClose.
+	UnreadDataPolicy UnreadDataPolicy
+
+	// OptimisticReadWindow, if set, asks the proxy (via
+	// XEnproxyOptimisticReadWindow) to hold the connect response just long
+	// enough to include up to this many bytes already read from the
+	// destination, so Conn can buffer them for the app's first Read
+	// without that Read waiting on a separate read op's round trip. This
+	// only helps protocols where the destination speaks first (an FTP
+	// banner, an SSH version string); for a client-speaks-first protocol
+	// it just adds a wasted wait to every connect, which is why it's off
+	// by default. The proxy must also implement the header to have any
+	// effect; one that doesn't understand it simply ignores it and
+	// returns its ordinary empty connect body.
+	OptimisticReadWindow int
+
+	// ReadBatchMaxBytes, if set, asks the proxy (via
+	// XEnproxyReadBatchMaxBytes) to accumulate up to this many bytes of
+	// destination data before responding to a read op, amortizing one
+	// request/response round trip over more data on bursty downloads,
+	// instead of returning as soon as anything is available. This package
+	// already streams an arbitrarily large single response incrementally
+	// (see processReads), so there's nothing for the client side to do
+	// beyond sending the header; accumulating across frames and deciding
+	// when to respond is entirely up to the proxy, and one that doesn't
+	// understand the header just responds as it always has.
+	ReadBatchMaxBytes int
+
+	// ReadBatchMaxWait, if set, asks the proxy (via
+	// XEnproxyReadBatchMaxWait) to bound how long it holds a read request
+	// open accumulating toward ReadBatchMaxBytes before responding with
+	// whatever it has. Has no effect unless ReadBatchMaxBytes is also set.
+	ReadBatchMaxWait time.Duration
+
+	// DNSCache, if set, is consulted and populated by DialTCP so that
+	// repeated dials to the same proxy address don't each pay for a fresh
+	// net.ResolveTCPAddr. It's shared by value, so one DNSCache can back
+	// every Conn created from this Config.
+	DNSCache *DNSCache
+
+	// FailFastWhenNoHealthy, if set, makes Conns fail a dial immediately
+	// with a *NoHealthyProxy error when DialProxy (for example one built
+	// with Pool.DialProxy) reports that no proxy is currently healthy,
+	// rather than retrying through backoff against a pool that's doomed to
+	// keep failing.
+	FailFastWhenNoHealthy bool
+
+	// RequestDiagnostics asks the proxy to include Diagnostics in its
+	// connect response, for later retrieval via Conn.Diagnostics. Off by
+	// default; the proxy must also opt in to honoring it, since doing so
+	// can leak internal details to whoever can reach it.
+	RequestDiagnostics bool
+
+	// StrictEOF controls how a Conn reacts if, despite the proxy signaling
+	// via XEnproxyEOF that the destination has closed, that same response
+	// still carries a body (for example due to response reordering or a
+	// buggy middlebox). When true, any such trailing data is discarded and
+	// reported as an error rather than delivered to Read. When false (the
+	// default), it's delivered to Read as usual before the connection is
+	// still treated as closed.
+	StrictEOF bool
+
+	// PowerMode sets the initial power mode a Conn polls with; see
+	// PowerMode and Conn.SetPowerMode. Defaults to PowerModeNormal.
+	PowerMode PowerMode
+
+	// DestLimiter, if set, caps how many Conns built from this Config may
+	// be concurrently open to any single destination address. See
+	// DestLimiter and BlockOnDestLimit.
+	DestLimiter *DestLimiter
+
+	// BlockOnDestLimit controls what happens when DestLimiter's limit is
+	// reached for a destination: if true, Connect blocks until a slot
+	// frees up; if false, it fails immediately with a *TooManyTunnels
+	// error. Has no effect if DestLimiter is nil.
+	BlockOnDestLimit bool
+
+	// DialLimiter, if set, caps how many Conns built from this Config may
+	// be dialing the proxy at once, queuing any beyond that limit instead
+	// of dialing them immediately. Unlike DestLimiter, which bounds
+	// concurrently open tunnels, DialLimiter only bounds the dial itself,
+	// smoothing a burst of simultaneous Connects (for example after a
+	// network change reconnects many tunnels at once) rather than letting
+	// them all dial at the same instant. See DialLimiter.
+	DialLimiter *DialLimiter
+
+	// OnClose, if set, is called exactly once when a Conn closes for any
+	// reason (explicit Close, an unrecoverable read/write error, idle
+	// timeout or redial exhaustion), with its final accumulated stats and
+	// why it closed. Nil is a no-op.
+	OnClose func(stats ConnStats, reason Reason)
+
+	// AllowHalfClosedWrites controls what happens once the proxy reports
+	// (via XEnproxyWriteClosed) that the destination's read side has
+	// closed. If true, the Conn stays open so Read can keep delivering
+	// whatever the destination still sends; if false (the default), the
+	// Conn is torn down like any other unrecoverable error. Either way,
+	// the triggering Write returns a *WriteClosed error.
+	AllowHalfClosedWrites bool
+
+	// ProxySocketLinger, if set, is applied to the underlying TCP socket
+	// for each dial to the proxy, controlling SO_LINGER behavior on close:
+	// zero requests an immediate RST discarding unsent data, a positive
+	// duration requests blocking the close to drain for up to that long.
+	// Nil leaves the OS default in place. Has no effect on non-TCP conns.
+	ProxySocketLinger *time.Duration
+
+	// ValidateProxyConn, if set, is consulted before a cached proxy
+	// connection is handed out for reuse, so that callers can add a cheap
+	// liveness probe (for example a tiny ping op) on top of the existing
+	// reuse logic. Returning false discards the connection and causes a
+	// fresh dial. Nil always reuses, which is the existing behavior.
+	ValidateProxyConn func(CachedConn) bool
+
+	// ValidateBeforeReuse enables a built-in version of the liveness probe
+	// ValidateProxyConn's doc describes: once a cached proxy connection has
+	// sat idle for longer than ValidateBeforeReuseMaxAge, a connection
+	// about to be reused for a new request is first checked with a tiny
+	// ping op before being handed out, so a connection a flaky NAT or
+	// load balancer silently dropped while idle is caught and redialed
+	// before it costs the request a full timeout. Requires proxy support
+	// for the ping op; a proxy that doesn't recognize it fails the probe
+	// and simply forces a redial, the same as if the connection really had
+	// gone dead. Runs in addition to, and before, ValidateProxyConn if
+	// both are set.
+	ValidateBeforeReuse bool
+
+	// ValidateBeforeReuseMaxAge is how long a cached proxy connection may
+	// sit idle before ValidateBeforeReuse starts probing it. Zero uses a 5
+	// second default. Has no effect unless ValidateBeforeReuse is set.
+	ValidateBeforeReuseMaxAge time.Duration
+
+	// OpHeaders, if set, supplies extra headers to add to requests for a
+	// given op (keyed by OpConnect, OpWrite, OpRead or OpClose), for
+	// example to set different cache directives on reads than on writes.
+	// They're merged into the request after NewRequest builds it and
+	// before the XEnproxyID/XEnproxyDestAddr/XEnproxyOp headers are set,
+	// so they can't shadow those; RequestInterceptor still runs last and
+	// can override anything, including these.
+	OpHeaders map[string]http.Header
+
+	// StreamCompression enables per-direction DEFLATE compression of
+	// compact-framed op payloads, each frame using the previous frames'
+	// bytes as a preset dictionary so the compression ratio on repetitive
+	// traffic keeps improving instead of restarting cold on every op the
+	// way per-request compression would. Requires CompactFraming, and only
+	// takes effect once the proxy has acknowledged the upgrade to it; the
+	// proxy must apply the matching scheme per connection id.
+	StreamCompression bool
+
+	// MaxConcurrentReads and MaxConcurrentWrites cap how many read and
+	// write ops, respectively, may have a request in flight to the proxy
+	// at once, bounding the resources a single Conn can consume. Zero (the
+	// default for each) leaves that direction unlimited, which preserves
+	// today's serial behavior since Conn currently only ever has at most
+	// one read op in flight at a time.
+	MaxConcurrentReads  int
+	MaxConcurrentWrites int
+
+	// ShapeFirstWrite, if set, is applied to the bytes of a Conn's very
+	// first Write call before they're sent as that write op's body, for
+	// example to pad or repack a TLS ClientHello so its on-wire shape
+	// doesn't match this package's own fingerprint. It sees exactly one
+	// Write's bytes, not the stream as a whole: a caller that splits its
+	// ClientHello across more than one Write only gets the first such
+	// call shaped. It never runs again for the same Conn, including
+	// across a redial, since from the destination's point of view a
+	// redial is a fresh TCP connection and whatever already crossed the
+	// old one doesn't need reshaping. Returning nil or an empty slice
+	// sends no bytes for that Write, which is almost certainly not what a
+	// ClientHello-shaping hook wants. Nil sends b unmodified, the
+	// existing behavior.
+	ShapeFirstWrite func(b []byte) []byte
+
+	// WriteAckMode chooses when Write returns relative to the write op it
+	// sends: WriteAckProxyReceived (the default, used when this is empty)
+	// returns once the proxy has responded to the write request, exactly
+	// today's behavior. WriteAckFireAndForget returns as soon as the
+	// request is queued, without waiting on the proxy at all, for
+	// throughput-sensitive callers that can tolerate not knowing whether
+	// a given Write made it; a write that ultimately fails in this mode
+	// is only visible indirectly, through Conn closing the way it already
+	// does on a ReconnectExhausted failure, or through ErrorCounts.
+	// WriteAckDestWritten additionally asks the proxy (via
+	// XEnproxyWriteAckMode) to hold its response until the destination
+	// socket itself has accepted the bytes, for callers that need that
+	// stronger guarantee; this package has no way to verify a proxy
+	// actually does so, so against one that doesn't implement it,
+	// WriteAckDestWritten behaves exactly like WriteAckProxyReceived.
+	WriteAckMode string
+
+	// Log, if set, receives a formatted one-line stats summary for the Conn
+	// every StatsLogInterval, for passive visibility into a long-running
+	// tunnel without the app having to poll Stats() itself. Has no effect
+	// unless StatsLogInterval is also set.
+	Log func(line string)
+
+	// StatsLogInterval, if nonzero, starts a background goroutine on
+	// Connect that calls Log with a stats summary at this interval,
+	// stopping cleanly when the Conn closes. Zero, the default, disables
+	// periodic logging entirely.
+	StatsLogInterval time.Duration
+}
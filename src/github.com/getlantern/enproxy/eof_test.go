@@ -0,0 +1,79 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// eofWithTrailingDataProxy answers the connect op normally, then on the
+// first read op signals EOF via XEnproxyEOF while still attaching a body,
+// simulating a buggy middlebox or reordered response.
+func eofWithTrailingDataProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\n" + XEnproxyEOF + ": true\r\nContent-Length: 5\r\n\r\nhello"))
+}
+
+func TestStrictEOFRejectsTrailingData(t *testing.T) {
+	cfg := testConfig()
+	cfg.StrictEOF = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go eofWithTrailingDataProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	b := make([]byte, 16)
+	_, err := conn.Read(b)
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	assert.NotEqual(t, io.EOF, err, "strict mode should surface an error, not a silent EOF")
+}
+
+func TestLenientEOFDeliversTrailingDataThenEOF(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go eofWithTrailingDataProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	b := make([]byte, 16)
+	n, err := conn.Read(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "hello", string(b[:n]))
+
+	_, err = conn.Read(b)
+	assert.Equal(t, io.EOF, err)
+}
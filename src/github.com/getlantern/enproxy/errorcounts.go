@@ -0,0 +1,122 @@
+package enproxy
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// errCategory buckets a failure by its likely cause, coarse enough to be
+// useful on a dashboard without requiring every error type this package
+// can produce to be enumerated by name.
+type errCategory int
+
+const (
+	errCategoryOther errCategory = iota
+	errCategoryProxyDial
+	errCategoryBadStatus
+	errCategoryTimeout
+	errCategoryReset
+)
+
+// classifyError buckets err for ErrorCounts. Errors with a distinct type
+// are matched directly; errors that doRequest/doRequestOnce have already
+// wrapped in a descriptive string (this package doesn't use %w, following
+// its existing error-handling convention) fall back to matching on that
+// message.
+func classifyError(err error) errCategory {
+	switch err.(type) {
+	case *NoHealthyProxy, *DialQueueTimeout:
+		return errCategoryProxyDial
+	case *AuthFailed, *RateLimited:
+		return errCategoryBadStatus
+	case *ConnectTimeout, *Stalled:
+		return errCategoryTimeout
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return errCategoryTimeout
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "reset"), strings.Contains(msg, "broken pipe"), strings.Contains(msg, "closed pipe"):
+		return errCategoryReset
+	case strings.Contains(msg, "dial"):
+		return errCategoryProxyDial
+	}
+	return errCategoryOther
+}
+
+// OpErrorCounts breaks down one op's observed failures by category.
+type OpErrorCounts struct {
+	ProxyDial int64 `json:"proxyDial"`
+	BadStatus int64 `json:"badStatus"`
+	Timeout   int64 `json:"timeout"`
+	Reset     int64 `json:"reset"`
+	Other     int64 `json:"other"`
+}
+
+func (o *OpErrorCounts) record(cat errCategory) {
+	switch cat {
+	case errCategoryProxyDial:
+		atomic.AddInt64(&o.ProxyDial, 1)
+	case errCategoryBadStatus:
+		atomic.AddInt64(&o.BadStatus, 1)
+	case errCategoryTimeout:
+		atomic.AddInt64(&o.Timeout, 1)
+	case errCategoryReset:
+		atomic.AddInt64(&o.Reset, 1)
+	default:
+		atomic.AddInt64(&o.Other, 1)
+	}
+}
+
+func (o *OpErrorCounts) snapshot() OpErrorCounts {
+	return OpErrorCounts{
+		ProxyDial: atomic.LoadInt64(&o.ProxyDial),
+		BadStatus: atomic.LoadInt64(&o.BadStatus),
+		Timeout:   atomic.LoadInt64(&o.Timeout),
+		Reset:     atomic.LoadInt64(&o.Reset),
+		Other:     atomic.LoadInt64(&o.Other),
+	}
+}
+
+// ErrorCounts breaks down a Conn's observed failures by the op that failed
+// and a coarse category, for pinpointing whether trouble clusters in
+// connection setup versus steady-state reads or writes.
+type ErrorCounts struct {
+	Connect OpErrorCounts `json:"connect"`
+	Read    OpErrorCounts `json:"read"`
+	Write   OpErrorCounts `json:"write"`
+}
+
+func (e *ErrorCounts) snapshot() ErrorCounts {
+	return ErrorCounts{
+		Connect: e.Connect.snapshot(),
+		Read:    e.Read.snapshot(),
+		Write:   e.Write.snapshot(),
+	}
+}
+
+// recordOpError classifies err and increments the matching op/category
+// counter in c.errorCounts, later retrievable via Conn.stats().
+func (c *Conn) recordOpError(op string, err error) {
+	counts := c.opErrorCounts(op)
+	if counts == nil {
+		return
+	}
+	counts.record(classifyError(err))
+}
+
+func (c *Conn) opErrorCounts(op string) *OpErrorCounts {
+	switch op {
+	case opConnect:
+		return &c.errorCounts.Connect
+	case opRead:
+		return &c.errorCounts.Read
+	case opWrite:
+		return &c.errorCounts.Write
+	default:
+		return nil
+	}
+}
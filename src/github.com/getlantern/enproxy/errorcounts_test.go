@@ -0,0 +1,89 @@
+package enproxy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordOpErrorClassifiesProxyDialFailureOnConnect(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opConnect, nil)
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+
+	counts := conn.stats().ErrorCounts
+	assert.Equal(t, int64(1), counts.Connect.ProxyDial)
+	assert.Equal(t, int64(0), counts.Connect.Other)
+	assert.Equal(t, OpErrorCounts{}, counts.Read)
+	assert.Equal(t, OpErrorCounts{}, counts.Write)
+}
+
+func TestRecordOpErrorClassifiesResetFailureOnWrite(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		_ = server.Close()
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opWrite, bytes.NewReader([]byte("hello")))
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+
+	counts := conn.stats().ErrorCounts
+	assert.Equal(t, int64(1), counts.Write.Reset)
+	assert.Equal(t, OpErrorCounts{}, counts.Connect)
+	assert.Equal(t, OpErrorCounts{}, counts.Read)
+}
+
+func TestRecordOpErrorClassifiesAuthFailedAsBadStatus(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer func() { _ = server.Close() }()
+			reader := bufio.NewReader(server)
+			for {
+				req, err := http.ReadRequest(reader)
+				if err != nil {
+					return
+				}
+				_, _ = io.Copy(io.Discard, req.Body)
+				if _, err := server.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")); err != nil {
+					return
+				}
+			}
+		}()
+		return client, nil
+	}
+	cfg.AuthHandler = func(challenge string) (string, error) {
+		return "Basic dGVzdDp0ZXN0", nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opConnect, nil)
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	if _, ok := err.(*AuthFailed); !assert.True(t, ok, "expected *AuthFailed, got %T: %v", err, err) {
+		t.FailNow()
+	}
+
+	counts := conn.stats().ErrorCounts
+	assert.Equal(t, int64(1), counts.Connect.BadStatus)
+}
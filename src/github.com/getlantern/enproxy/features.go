@@ -0,0 +1,102 @@
+package enproxy
+
+import "fmt"
+
+// Feature names an optional, negotiated capability, for Config.RequireFeatures.
+type Feature string
+
+const (
+	// FeatureFraming corresponds to ActiveFeatures.Framing.
+	FeatureFraming Feature = "framing"
+
+	// FeatureCompression corresponds to ActiveFeatures.Compression. Naming
+	// it in RequireFeatures always fails Connect, since this package
+	// doesn't implement compression negotiation yet.
+	FeatureCompression Feature = "compression"
+
+	// FeatureKeepalive corresponds to ActiveFeatures.Keepalive. Naming it
+	// in RequireFeatures always fails Connect, since this package doesn't
+	// implement keepalive negotiation yet.
+	FeatureKeepalive Feature = "keepalive"
+
+	// FeatureResume corresponds to ActiveFeatures.Resume. Naming it in
+	// RequireFeatures always fails Connect, since this package doesn't
+	// implement resume negotiation yet.
+	FeatureResume Feature = "resume"
+)
+
+// RequiredFeatureUnavailable is returned by Connect when Config.RequireFeatures
+// names a feature the proxy didn't end up supporting (or that this package
+// doesn't implement negotiating at all).
+type RequiredFeatureUnavailable struct {
+	// Feature is the RequireFeatures entry that couldn't be satisfied.
+	Feature Feature
+}
+
+func (e *RequiredFeatureUnavailable) Error() string {
+	return fmt.Sprintf("required feature %q is not available on this connection", e.Feature)
+}
+
+// checkRequiredFeatures fails fast with a *RequiredFeatureUnavailable if any
+// of Config.RequireFeatures didn't end up active, once negotiation (such as
+// tryUpgradeToCompactFraming) has already run. Features this package
+// doesn't implement negotiating -- compression, keepalive, resume -- can
+// never be active, so naming one always fails this check rather than
+// silently succeeding.
+func (c *Conn) checkRequiredFeatures() error {
+	if len(c.RequireFeatures) == 0 {
+		return nil
+	}
+	active := c.ActiveFeatures()
+	for _, f := range c.RequireFeatures {
+		var ok bool
+		switch f {
+		case FeatureFraming:
+			ok = active.Framing
+		case FeatureCompression:
+			ok = active.Compression
+		case FeatureKeepalive:
+			ok = active.Keepalive
+		case FeatureResume:
+			ok = active.Resume
+		default:
+			return fmt.Errorf("RequireFeatures names unrecognized feature %q", f)
+		}
+		if !ok {
+			return &RequiredFeatureUnavailable{Feature: f}
+		}
+	}
+	return nil
+}
+
+// ActiveFeatures reports which optional features actually ended up active on
+// a Conn after negotiating with the proxy, which may differ from what was
+// requested in Config if the proxy didn't support it. Only Framing is
+// populated today; the others report false until this package grows the
+// corresponding negotiation.
+type ActiveFeatures struct {
+	// Framing is true if the proxy accepted the switch to compact binary
+	// framing requested by Config.CompactFraming.
+	Framing bool
+
+	// Compression is true if response bodies are being compressed. Not yet
+	// implemented by this package.
+	Compression bool
+
+	// Keepalive is true if the proxy connection is being kept alive between
+	// ops rather than redialed per request. Not yet implemented by this
+	// package.
+	Keepalive bool
+
+	// Resume is true if an interrupted tunnel can be resumed without losing
+	// data. Not yet implemented by this package.
+	Resume bool
+}
+
+// ActiveFeatures returns which optional features are actually active on c,
+// as settled during Connect. It's a cheap read of already-stored state.
+func (c *Conn) ActiveFeatures() ActiveFeatures {
+	return ActiveFeatures{
+		Framing: c.isUpgradedToCompactFraming(),
+	}
+}
@@ -0,0 +1,94 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFeatureDegradesWhenProxyDoesntSupportIt confirms that, absent
+// RequireFeatures, a proxy that doesn't support CompactFraming just leaves
+// it off rather than failing Connect.
+func TestFeatureDegradesWhenProxyDoesntSupportIt(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.False(t, conn.ActiveFeatures().Framing)
+}
+
+// TestRequireFeaturesFailsConnectWhenUnavailable confirms that naming a
+// feature in RequireFeatures turns a silent downgrade into a hard failure.
+func TestRequireFeaturesFailsConnectWhenUnavailable(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.RequireFeatures = []Feature{FeatureFraming}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	err := conn.Connect()
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	unavailable, ok := err.(*RequiredFeatureUnavailable)
+	if !assert.True(t, ok, "expected *RequiredFeatureUnavailable, got %T", err) {
+		t.FailNow()
+	}
+	assert.Equal(t, FeatureFraming, unavailable.Feature)
+}
+
+// TestRequireFeaturesSucceedsWhenNegotiated confirms RequireFeatures doesn't
+// get in the way once the proxy actually grants the feature.
+func TestRequireFeaturesSucceedsWhenNegotiated(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.RequireFeatures = []Feature{FeatureFraming}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go compactFramingProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.True(t, conn.ActiveFeatures().Framing)
+}
+
+// TestRequireFeaturesRejectsUnimplementedFeature confirms naming a feature
+// this package hasn't implemented negotiating at all -- compression,
+// keepalive, resume -- fails Connect rather than silently succeeding, since
+// it can never actually be active.
+func TestRequireFeaturesRejectsUnimplementedFeature(t *testing.T) {
+	cfg := testConfig()
+	cfg.RequireFeatures = []Feature{FeatureCompression}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	err := conn.Connect()
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	unavailable, ok := err.(*RequiredFeatureUnavailable)
+	if !assert.True(t, ok, "expected *RequiredFeatureUnavailable, got %T", err) {
+		t.FailNow()
+	}
+	assert.Equal(t, FeatureCompression, unavailable.Feature)
+}
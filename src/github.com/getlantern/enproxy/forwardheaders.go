@@ -0,0 +1,40 @@
+package enproxy
+
+import "net/http"
+
+// recordForwardedHeaders copies whatever of Config.ForwardResponseHeaders
+// header actually carries into c's forwarded set, overwriting any prior
+// value for the same header -- later responses (e.g. a later read op) win
+// over earlier ones, since a header like a destination's changing cache
+// status is only meaningful as of the most recent response.
+func (c *Conn) recordForwardedHeaders(header http.Header) {
+	if len(c.ForwardResponseHeaders) == 0 {
+		return
+	}
+	c.forwardedHeadersMutex.Lock()
+	defer c.forwardedHeadersMutex.Unlock()
+	for _, name := range c.ForwardResponseHeaders {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if c.forwardedHeaders == nil {
+			c.forwardedHeaders = make(http.Header)
+		}
+		c.forwardedHeaders[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+}
+
+// ForwardedHeaders returns the most recent values of whatever headers
+// Config.ForwardResponseHeaders named, as seen across all of this Conn's
+// responses so far. Empty if ForwardResponseHeaders is unset or none of the
+// named headers have appeared yet.
+func (c *Conn) ForwardedHeaders() http.Header {
+	c.forwardedHeadersMutex.Lock()
+	defer c.forwardedHeadersMutex.Unlock()
+	result := make(http.Header, len(c.forwardedHeaders))
+	for k, v := range c.forwardedHeaders {
+		result[k] = append([]string(nil), v...)
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// headerReturningProxy answers every request with a 200 bearing header,
+// bumping X-Request-Count on each response so tests can confirm
+// ForwardedHeaders picks up the latest value rather than the first.
+func headerReturningProxy(conn net.Conn, header string, values []string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for i := 0; i < len(values); i++ {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		resp := "HTTP/1.1 200 OK\r\n" + header + ": " + values[i] + "\r\nContent-Length: 0\r\n\r\n"
+		if _, err := conn.Write([]byte(resp)); err != nil {
+			return
+		}
+	}
+}
+
+// TestForwardedHeadersCollectsNamedHeaders confirms Config.ForwardResponseHeaders
+// surfaces only the headers it names, keeping the latest value across calls.
+func TestForwardedHeadersCollectsNamedHeaders(t *testing.T) {
+	cfg := testConfig()
+	cfg.ForwardResponseHeaders = []string{"X-Cache-Status"}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go headerReturningProxy(server, "X-Cache-Status", []string{"MISS", "HIT"})
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, "MISS", conn.ForwardedHeaders().Get("X-Cache-Status"))
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, "HIT", conn.ForwardedHeaders().Get("X-Cache-Status"))
+}
+
+// TestForwardedHeadersEmptyWhenUnconfigured confirms a Conn with no
+// ForwardResponseHeaders never collects anything, even though responses
+// carry headers it could otherwise have grabbed.
+func TestForwardedHeadersEmptyWhenUnconfigured(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go headerReturningProxy(server, "X-Cache-Status", []string{"MISS"})
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Empty(t, conn.ForwardedHeaders())
+}
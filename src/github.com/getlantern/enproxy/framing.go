@@ -0,0 +1,152 @@
+package enproxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Compact framing replaces the verbose per-op HTTP request/response with a
+// minimal fixed binary header once a persistent connection to the proxy has
+// been established and the proxy has acknowledged an upgrade to this mode.
+// It's only worthwhile for deployments that control both client and server
+// and want near-raw-TCP overhead on a latency-sensitive tunnel; everyone
+// else should leave Config.CompactFraming false and keep using the
+// HTTP-compatible (and thus more traversal-friendly) framing.
+const (
+	frameConnect byte = 1
+	frameWrite   byte = 2
+	frameRead    byte = 3
+	frameClose   byte = 4
+
+	frameStatusOK    byte = 0
+	frameStatusError byte = 1
+
+	// compactUpgradeHeader, when sent with value "1" on a connect request,
+	// asks the proxy to switch this connection to compact framing for the
+	// remainder of its lifetime. The proxy acknowledges with 101 Switching
+	// Protocols.
+	compactUpgradeHeader = "X-Enproxy-Compact-Framing"
+)
+
+// maxCompactFramePayload is the default payload length readCompactFrame
+// will believe from the wire when Config.MaxReassemblyBytes is unset, so
+// that a hostile or buggy proxy sending a huge length prefix can't make the
+// client allocate gigabytes for a payload that will never actually arrive.
+const maxCompactFramePayload = 16 * 1024 * 1024
+
+// maxReassemblyBytes returns the configured cap on a single compact frame's
+// payload length, or the package default if Config.MaxReassemblyBytes is
+// unset.
+func (c *Conn) maxReassemblyBytes() uint32 {
+	if c.MaxReassemblyBytes > 0 {
+		return uint32(c.MaxReassemblyBytes)
+	}
+	return maxCompactFramePayload
+}
+
+// writeCompactFrame writes a single compact-framed request: a one byte op,
+// a length-prefixed connection id, a length-prefixed destination address,
+// and a length-prefixed payload.
+func writeCompactFrame(w io.Writer, op byte, id string, addr string, payload []byte) error {
+	header := make([]byte, 0, 1+2+len(id)+2+len(addr)+4)
+	header = append(header, op)
+	header = appendUint16Prefixed(header, []byte(id))
+	header = appendUint16Prefixed(header, []byte(addr))
+
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(payload)))
+	header = append(header, lenBuf[:]...)
+
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("Unable to write compact frame header: %v", err)
+	}
+	if len(payload) > 0 {
+		if _, err := w.Write(payload); err != nil {
+			return fmt.Errorf("Unable to write compact frame payload: %v", err)
+		}
+	}
+	return nil
+}
+
+func appendUint16Prefixed(dst []byte, data []byte) []byte {
+	var lenBuf [2]byte
+	binary.BigEndian.PutUint16(lenBuf[:], uint16(len(data)))
+	dst = append(dst, lenBuf[:]...)
+	return append(dst, data...)
+}
+
+// readCompactFrame reads a single compact-framed response: a one byte
+// status followed by a length-prefixed payload. maxPayload bounds the
+// payload length it will believe from the wire; see Config.MaxReassemblyBytes.
+func readCompactFrame(r *bufio.Reader, maxPayload uint32) (status byte, payload []byte, err error) {
+	status, err = r.ReadByte()
+	if err != nil {
+		return 0, nil, fmt.Errorf("Unable to read compact frame status: %v", err)
+	}
+
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return 0, nil, fmt.Errorf("Unable to read compact frame length: %v", err)
+	}
+	length := binary.BigEndian.Uint32(lenBuf[:])
+	if length > maxPayload {
+		return 0, nil, fmt.Errorf("compact frame payload length %d exceeds maximum of %d", length, maxPayload)
+	}
+
+	payload = make([]byte, length)
+	if length > 0 {
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return 0, nil, fmt.Errorf("Unable to read compact frame payload: %v", err)
+		}
+	}
+	return status, payload, nil
+}
+
+// doCompactRequest issues op over an already-upgraded connection using
+// compact framing instead of HTTP, returning the response payload.
+func (c *Conn) doCompactRequest(conn net.Conn, reader *bufio.Reader, op byte, payload []byte) ([]byte, error) {
+	if c.StreamCompression {
+		compressed, err := c.compressPayload(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = compressed
+	}
+
+	if err := writeCompactFrame(conn, op, c.id, c.addr, payload); err != nil {
+		return nil, err
+	}
+	status, respPayload, err := readCompactFrame(reader, c.maxReassemblyBytes())
+	if err != nil {
+		return nil, err
+	}
+	if status != frameStatusOK {
+		return nil, fmt.Errorf("proxy returned error for compact op %d: %s", op, respPayload)
+	}
+
+	if c.StreamCompression {
+		decompressed, err := c.decompressPayload(respPayload)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decompress response for compact op %d: %v", op, err)
+		}
+		respPayload = decompressed
+	}
+
+	return respPayload, nil
+}
+
+func opToFrameOp(op string) byte {
+	switch op {
+	case opWrite:
+		return frameWrite
+	case opRead:
+		return frameRead
+	case opClose:
+		return frameClose
+	default:
+		return frameConnect
+	}
+}
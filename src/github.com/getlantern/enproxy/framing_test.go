@@ -0,0 +1,213 @@
+package enproxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// compactFramingProxy accepts the HTTP connect handshake, acknowledges the
+// compact framing upgrade, and then echoes back the payload of any compact
+// write frame it receives as the payload of the next compact read frame.
+func compactFramingProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	if req.Header.Get(compactUpgradeHeader) == "" {
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+		return
+	}
+	_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" + compactUpgradeHeader + ": 1\r\n\r\n"))
+
+	var lastPayload []byte
+	for {
+		op, _, _, payload, err := readCompactRequestFrame(reader)
+		if err != nil {
+			return
+		}
+
+		switch op {
+		case frameWrite:
+			lastPayload = payload
+			_ = writeCompactResponseFrame(conn, frameStatusOK, nil)
+		case frameRead:
+			_ = writeCompactResponseFrame(conn, frameStatusOK, lastPayload)
+			lastPayload = nil
+		default:
+			_ = writeCompactResponseFrame(conn, frameStatusOK, nil)
+		}
+	}
+}
+
+// readCompactRequestFrame reads a single frame in the format written by
+// writeCompactFrame: a one byte op, a length-prefixed id, a length-prefixed
+// addr and a length-prefixed payload.
+func readCompactRequestFrame(r *bufio.Reader) (op byte, id, addr string, payload []byte, err error) {
+	op, err = r.ReadByte()
+	if err != nil {
+		return 0, "", "", nil, err
+	}
+	idBytes, err := readUint16Prefixed(r)
+	if err != nil {
+		return 0, "", "", nil, err
+	}
+	addrBytes, err := readUint16Prefixed(r)
+	if err != nil {
+		return 0, "", "", nil, err
+	}
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return 0, "", "", nil, err
+	}
+	length := binary.BigEndian.Uint32(lenBuf[:])
+	payload = make([]byte, length)
+	if length > 0 {
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return 0, "", "", nil, err
+		}
+	}
+	return op, string(idBytes), string(addrBytes), payload, nil
+}
+
+func readUint16Prefixed(r *bufio.Reader) ([]byte, error) {
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	length := int(lenBuf[0])<<8 | int(lenBuf[1])
+	buf := make([]byte, length)
+	if length > 0 {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// writeCompactResponseFrame writes a response in the format expected by
+// readCompactFrame: a one byte status followed by a length-prefixed
+// payload.
+func writeCompactResponseFrame(w io.Writer, status byte, payload []byte) error {
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(payload)))
+	if _, err := w.Write(append([]byte{status}, lenBuf[:]...)); err != nil {
+		return err
+	}
+	if len(payload) > 0 {
+		_, err := w.Write(payload)
+		return err
+	}
+	return nil
+}
+
+func TestCompactFramingUpgrade(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go compactFramingProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+	assert.True(t, conn.isUpgradedToCompactFraming(), "proxy accepted the upgrade, Conn should have switched to compact framing")
+
+	_, err := conn.doRequest(opWrite, nil)
+	assert.NoError(t, err)
+}
+
+// TestCompactFramingRoundTripsRealPayload drives two full Connect/Write/Read
+// cycles with non-empty payloads, rather than a raw doRequest call that
+// discards its response: a regression test for processReads flagging a
+// compact-framed read op with real data as an *OverlongResponse, since
+// doCompactRequestAsResponse used to leave ContentLength at its zero value
+// regardless of how much the frame actually carried. The bug let the first
+// round trip succeed -- the bad response had already been delivered to Read
+// by the time processReads got around to checking it -- and only surfaced on
+// the very next Read, once processReads had torn the connection down out
+// from under it.
+func TestCompactFramingRoundTripsRealPayload(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go compactFramingProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+	assert.True(t, conn.isUpgradedToCompactFraming(), "proxy accepted the upgrade, Conn should have switched to compact framing")
+
+	for _, payload := range [][]byte{
+		[]byte("hello through compact framing"),
+		[]byte("a second payload on the same Conn"),
+	} {
+		_, err := conn.Write(payload)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		b := make([]byte, len(payload))
+		n, err := io.ReadFull(conn, b)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, payload, b[:n])
+	}
+}
+
+// TestCompactFramingWithPayloadKeyRoundTripsRealPayload confirms the two
+// features don't conflict when both are configured: CompactFraming's
+// ContentLength -1 fix must hold regardless of PayloadKey also being set.
+func TestCompactFramingWithPayloadKeyRoundTripsRealPayload(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.PayloadKey = testPayloadKey
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go compactFramingProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+	assert.True(t, conn.isUpgradedToCompactFraming(), "proxy accepted the upgrade, Conn should have switched to compact framing")
+
+	for _, payload := range [][]byte{
+		[]byte("hello through compact framing with a payload key"),
+		[]byte("a second payload on the same Conn"),
+	} {
+		_, err := conn.Write(payload)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		b := make([]byte, len(payload))
+		n, err := io.ReadFull(conn, b)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, payload, b[:n])
+	}
+}
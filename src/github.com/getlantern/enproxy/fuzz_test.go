@@ -0,0 +1,46 @@
+package enproxy
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// FuzzResponseParsing feeds malformed HTTP responses and compact-framed
+// responses to the client-side parsing code it shares with doRequestOnce
+// and doCompactRequest, asserting it never panics on any input, valid or
+// garbage.
+func FuzzResponseParsing(f *testing.F) {
+	f.Add([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	f.Add([]byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n"))
+	f.Add([]byte("not even an http response"))
+	f.Add([]byte{})
+	f.Add([]byte{0, 0, 0, 0, 0})
+	f.Add([]byte{frameStatusOK, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("http.ReadResponse path panicked on %q: %v", data, r)
+				}
+			}()
+			resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+			if err == nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}
+		}()
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("readCompactFrame panicked on %q: %v", data, r)
+				}
+			}()
+			_, _, _ = readCompactFrame(bufio.NewReader(bytes.NewReader(data)), maxCompactFramePayload)
+		}()
+	})
+}
@@ -0,0 +1,24 @@
+package enproxy
+
+import "sync/atomic"
+
+// GoroutineCount reports how many background goroutines c currently has
+// running (its read-polling loop, plus any transient ones finishing up an
+// async close or a stalled read), for leak detection in long-running
+// clients. It settles to zero shortly after Close returns.
+func (c *Conn) GoroutineCount() int {
+	return int(atomic.LoadInt32(&c.activeGoroutines))
+}
+
+// goroutineStarted records that a background goroutine belonging to c is
+// about to start; call it synchronously right before the `go` statement.
+func (c *Conn) goroutineStarted() {
+	atomic.AddInt32(&c.activeGoroutines, 1)
+}
+
+// goroutineStopped records that a background goroutine belonging to c has
+// exited. Every goroutineStarted call must be paired with exactly one of
+// these, normally via defer at the top of the goroutine's body.
+func (c *Conn) goroutineStopped() {
+	atomic.AddInt32(&c.activeGoroutines, -1)
+}
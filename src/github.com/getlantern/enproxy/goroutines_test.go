@@ -0,0 +1,70 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// longPollingProxy answers the connect op immediately, then answers every
+// read op only after a short delay, simulating a normal long-polling
+// proxy so processReads stays busy rather than idling instantly.
+func longPollingProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		if req.Header.Get(XEnproxyOp) == opRead {
+			time.Sleep(10 * time.Millisecond)
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestGoroutineCountReturnsToZeroAfterClose(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go longPollingProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	if !assert.True(t, waitFor(time.Second, func() bool { return conn.GoroutineCount() >= 1 }),
+		"expected processReads to be counted as running") {
+		t.FailNow()
+	}
+
+	if !assert.NoError(t, conn.Close()) {
+		t.FailNow()
+	}
+
+	assert.True(t, waitFor(time.Second, func() bool { return conn.GoroutineCount() == 0 }),
+		"expected all of Conn's goroutines to be reaped after Close")
+}
+
+// waitFor polls cond every millisecond until it's true or timeout elapses,
+// returning whether it was ever observed true.
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
@@ -0,0 +1,16 @@
+package enproxy
+
+// XEnproxyWriteClosed, set to "true" in a write response, tells the client
+// that the destination's read side has closed (so further writes will
+// fail) while its write side may still be open and producing data.
+const XEnproxyWriteClosed = "X-Enproxy-Write-Closed"
+
+// WriteClosed is returned from Write once the destination's read side has
+// closed. If Config.AllowHalfClosedWrites is true, the Conn stays open and
+// Read continues to work normally; otherwise the Conn is torn down like any
+// other unrecoverable error.
+type WriteClosed struct{}
+
+func (e *WriteClosed) Error() string {
+	return "destination closed its read side; further writes will fail"
+}
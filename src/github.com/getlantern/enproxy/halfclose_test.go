@@ -0,0 +1,63 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// halfClosedWriteProxy acknowledges connect normally, then reports the
+// destination's read side as closed on the first write op.
+func halfClosedWriteProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\n" + XEnproxyWriteClosed + ": true\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestWriteClosedKeepsConnOpenWhenAllowed(t *testing.T) {
+	cfg := testConfig()
+	cfg.AllowHalfClosedWrites = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go halfClosedWriteProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	// Connect directly via doRequest rather than conn.Connect, so the
+	// background processReads loop it would start doesn't race the
+	// write op below against this test's two-request fake proxy.
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+	defer func() { _ = conn.Close() }()
+
+	_, err = conn.Write([]byte("hi"))
+	_, ok := err.(*WriteClosed)
+	assert.True(t, ok, "expected a *WriteClosed error, got %T: %v", err, err)
+
+	select {
+	case <-conn.closeCh:
+		t.Fatal("Conn should stay open when AllowHalfClosedWrites is true")
+	default:
+	}
+}
@@ -0,0 +1,102 @@
+package enproxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/getlantern/idletiming"
+)
+
+// Stalled is returned from Read when data was expected mid-response but
+// none arrived within Config.StallTimeout. This is distinct from an empty
+// long-poll response, which just means the destination currently has
+// nothing to send and is not an error.
+type Stalled struct {
+	// Waited is how long Read waited for more data before giving up.
+	Waited time.Duration
+}
+
+func (e *Stalled) Error() string {
+	return fmt.Sprintf("no data received for %s mid-response, proxy connection may have stalled", e.Waited)
+}
+
+// maybeWrapIdleTiming wraps conn with an idle timeout, closing it after
+// c.IdleTimeout of complete inactivity and then running c.handleIdleTimeout
+// (see Config.OnIdle). A long-poll read that comes back empty still counts
+// as activity, since it's evidence the connection to the proxy is alive
+// even though the destination had nothing to send. Config.DisableIdleTiming
+// overrides IdleTimeout, for callers sharing a Config across Conns that
+// don't all want the wrapper (and its background timer goroutine) applied.
+func (c *Conn) maybeWrapIdleTiming(conn net.Conn) net.Conn {
+	if c.IdleTimeout <= 0 || c.DisableIdleTiming {
+		return conn
+	}
+	return idletiming.Conn(conn, c.IdleTimeout, c.handleIdleTimeout)
+}
+
+// IdleAction decides what happens once a Conn's connection to the proxy has
+// already been closed for going idle; see Config.OnIdle.
+type IdleAction int
+
+const (
+	// IdleClose is the default: the now-closed connection is left cached as
+	// is. The next request against it fails (it's closed), and like any
+	// other unrecoverable transport error that tears down the logical Conn.
+	IdleClose IdleAction = iota
+
+	// IdleKeepalive forgets the now-closed connection instead of leaving it
+	// cached, so the next request transparently redials rather than being
+	// handed a dead connection and failing. A request already in flight at
+	// the moment idle fires still fails the same as under IdleClose, since
+	// there's no reviving a connection idletiming has already closed; pair
+	// this with a Config.ShouldRetry that retries reads to smooth over that
+	// one request too, so quiet tunnels survive idling out indefinitely
+	// without the app having to reconnect itself. Polling for redials this
+	// way instead of pinging to stay active trades a little latency on the
+	// next request for not needing the proxy to understand a distinct ping
+	// op, and for not running an extra always-on background timer per Conn.
+	IdleKeepalive
+)
+
+// handleIdleTimeout runs after c's connection to the proxy has already
+// closed for going idle, consulting Config.OnIdle (defaulting to IdleClose
+// when unset) for what to do about it.
+func (c *Conn) handleIdleTimeout() {
+	action := IdleClose
+	if c.OnIdle != nil {
+		action = c.OnIdle(c)
+	}
+	if action == IdleKeepalive {
+		c.closeProxyConn()
+	}
+}
+
+// readWithStallTimeout reads from r into buf, returning a *Stalled error if
+// c.StallTimeout elapses with no data and no error. A zero StallTimeout
+// disables this and just calls r.Read directly.
+func (c *Conn) readWithStallTimeout(r io.Reader, buf []byte) (int, error) {
+	if c.StallTimeout <= 0 {
+		return r.Read(buf)
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	resultCh := make(chan result, 1)
+	c.goroutineStarted()
+	go func() {
+		defer c.goroutineStopped()
+		n, err := r.Read(buf)
+		resultCh <- result{n, err}
+	}()
+
+	select {
+	case res := <-resultCh:
+		return res.n, res.err
+	case <-time.After(c.StallTimeout):
+		return 0, &Stalled{Waited: c.StallTimeout}
+	}
+}
@@ -0,0 +1,66 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stallingProxy responds normally to the connect op, then on the first read
+// op writes a single chunk and never finishes the response, simulating a
+// mid-transfer stall.
+func stallingProxy(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n"))
+	_, _ = conn.Write([]byte("5\r\nhello\r\n"))
+	// Deliberately never send the final chunk; let the pipe sit idle.
+}
+
+func TestReadReturnsStalledOnMidResponseGap(t *testing.T) {
+	cfg := testConfig()
+	cfg.StallTimeout = 250 * time.Millisecond
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go stallingProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	// Deliberately not closing conn: the proxy in this test never finishes
+	// its response, so a graceful Close's own request would block forever
+	// behind it. The underlying net.Pipe is cleaned up when the test
+	// process's goroutines exit.
+
+	b := make([]byte, 5)
+	n, err := conn.Read(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "hello", string(b[:n]))
+
+	_, err = conn.Read(b)
+	assert.Error(t, err)
+	_, isStalled := err.(*Stalled)
+	assert.True(t, isStalled, "expected a *Stalled error, got %T: %v", err, err)
+}
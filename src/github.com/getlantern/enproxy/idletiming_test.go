@@ -0,0 +1,26 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisableIdleTimingSkipsWrapper(t *testing.T) {
+	cfg := testConfig()
+	cfg.IdleTimeout = time.Minute
+
+	client, server := net.Pipe()
+	defer func() { _ = server.Close() }()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	wrapped := conn.maybeWrapIdleTiming(client)
+	assert.NotEqual(t, client, wrapped, "IdleTimeout alone should wrap the conn")
+
+	cfg.DisableIdleTiming = true
+	conn = cfg.NewConn("dest.example.com:80")
+	unwrapped := conn.maybeWrapIdleTiming(client)
+	assert.Equal(t, client, unwrapped, "DisableIdleTiming should skip the idle-timeout wrapper even with IdleTimeout set")
+}
@@ -0,0 +1,43 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// earlyHintsProxy answers the connect op with a 103 Early Hints followed by
+// the actual 200, simulating a proxy or intermediary that emits
+// informational responses ahead of the final one.
+func earlyHintsProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 103 Early Hints\r\nLink: </style.css>; rel=preload\r\n\r\n"))
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestDoRequestSkipsInformationalResponses(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go earlyHintsProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = resp.Body.Close() }()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,33 @@
+package enproxy
+
+import "net"
+
+// maybeSetLinger applies c.ProxySocketLinger to conn if conn is a
+// *net.TCPConn and the option is set, controlling what happens when the
+// proxy socket is closed:
+//
+//   - nil (the default): leave the OS default behavior in place.
+//   - zero: discard any unsent data and send an immediate RST rather than
+//     draining, for operators that would rather see a clean disconnect
+//     accounted for at once than have a socket linger in TIME_WAIT.
+//   - positive: block the close for up to that long draining unsent data
+//     before giving up, for a more graceful teardown. SO_LINGER counts whole
+//     seconds, so the duration is truncated to whole seconds, and anything
+//     under a second counts as one.
+//
+// It's a no-op (not an error) for non-TCP conns, such as the net.Pipe used
+// in tests, since linger is a TCP-specific concept.
+func (c *Conn) maybeSetLinger(conn net.Conn) error {
+	if c.ProxySocketLinger == nil {
+		return nil
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	secs := int(c.ProxySocketLinger.Seconds())
+	if secs == 0 && *c.ProxySocketLinger > 0 {
+		secs = 1
+	}
+	return tcpConn.SetLinger(secs)
+}
@@ -0,0 +1,50 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaybeSetLingerNoopForNonTCP(t *testing.T) {
+	linger := time.Duration(0)
+	cfg := testConfig()
+	cfg.ProxySocketLinger = &linger
+
+	conn := cfg.NewConn("dest.example.com:80")
+	client, _ := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	assert.NoError(t, conn.maybeSetLinger(client))
+}
+
+func TestMaybeSetLingerAppliesToTCPConn(t *testing.T) {
+	linger := time.Duration(0)
+	cfg := testConfig()
+	cfg.ProxySocketLinger = &linger
+
+	conn := cfg.NewConn("dest.example.com:80")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = ln.Close() }()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = client.Close() }()
+	<-accepted
+
+	assert.NoError(t, conn.maybeSetLinger(client))
+}
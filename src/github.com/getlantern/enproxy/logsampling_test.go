@@ -0,0 +1,72 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// allOpsIDCapturingProxy answers connect, one write and one read op, each
+// with a 200, recording the XEnproxyID and XEnproxyOp it saw on every one.
+func allOpsIDCapturingProxy(conn net.Conn, seenCh chan<- string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 3; i++ {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		seenCh <- req.Header.Get(XEnproxyOp) + ":" + req.Header.Get(XEnproxyID)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+// TestIDStableAcrossAllOpsOfATunnel verifies the precondition a server
+// needs to implement per-tunnel sampled request/response logging (request
+// #synth-242's LogSampleRate, which lives server-side and so isn't
+// implemented in this package): every op of a tunnel, not just a retried
+// connect, carries the same XEnproxyID, so a server that decides once at
+// connect whether to sample a tunnel can apply that decision consistently
+// to every later request without re-deciding per op.
+func TestIDStableAcrossAllOpsOfATunnel(t *testing.T) {
+	seenCh := make(chan string, 3)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go allOpsIDCapturingProxy(server, seenCh)
+		return client, nil
+	}
+
+	// doRequest is called directly, rather than through Connect/Write,
+	// so this test isn't racing processReads' own background opRead
+	// requests over the same proxy connection.
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	resp, err = conn.doRequest(opRead, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	connectSeen := <-seenCh
+	writeSeen := <-seenCh
+	readSeen := <-seenCh
+
+	assert.Equal(t, opConnect+":"+conn.id, connectSeen)
+	assert.Equal(t, opWrite+":"+conn.id, writeSeen)
+	assert.Equal(t, opRead+":"+conn.id, readSeen)
+}
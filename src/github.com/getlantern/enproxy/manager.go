@@ -0,0 +1,84 @@
+package enproxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Manager tracks the Conns dialed from a given Config and allows them all to
+// be torn down together, for example when an app is shutting down and would
+// otherwise have to keep track of every tunnel it opened individually.
+//
+// A Manager's zero value is not usable; construct one with NewManager.
+type Manager struct {
+	cfg Config
+
+	mu     sync.Mutex
+	conns  map[*Conn]bool
+	sealed bool
+}
+
+// NewManager creates a Manager that dials Conns using cfg.
+func NewManager(cfg Config) *Manager {
+	return &Manager{
+		cfg:   cfg,
+		conns: make(map[*Conn]bool),
+	}
+}
+
+// NewConn creates a new Conn to addr and tracks it for future CloseAll
+// calls. Like Config.NewConn, it doesn't connect the Conn; the caller still
+// calls Connect on it. It fails if the Manager has been sealed.
+func (m *Manager) NewConn(addr string) (*Conn, error) {
+	m.mu.Lock()
+	if m.sealed {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("Manager is sealed, not dialing new Conn to %v", addr)
+	}
+	conn := m.cfg.NewConn(addr)
+	m.conns[conn] = true
+	m.mu.Unlock()
+	return conn, nil
+}
+
+// Seal prevents the Manager from dialing any further Conns. Conns that are
+// already tracked continue to work normally until closed.
+func (m *Manager) Seal() {
+	m.mu.Lock()
+	m.sealed = true
+	m.mu.Unlock()
+}
+
+// CloseAll closes all Conns currently tracked by this Manager, stopping
+// early and returning ctx.Err() if ctx is canceled or its deadline passes
+// before all Conns have finished closing. CloseAll does not seal the
+// Manager; unless Seal has been called separately, new Conns dialed after
+// CloseAll returns are tracked as usual.
+func (m *Manager) CloseAll(ctx context.Context) error {
+	m.mu.Lock()
+	conns := make([]*Conn, 0, len(m.conns))
+	for conn := range m.conns {
+		conns = append(conns, conn)
+	}
+	m.conns = make(map[*Conn]bool)
+	m.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		var firstErr error
+		for _, conn := range conns {
+			if err := conn.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		done <- firstErr
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
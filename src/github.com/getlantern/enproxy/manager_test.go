@@ -0,0 +1,79 @@
+package enproxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testConfig returns a Config whose DialProxy spins up an in-memory server
+// (via net.Pipe) that responds 200 OK to every request it receives.
+func testConfig() Config {
+	return Config{
+		Host: "proxy.example.com",
+		DialProxy: func(addr string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go serveFakeProxy(server)
+			return client, nil
+		},
+		NewRequest: func(upstreamHost string, method string, body io.Reader) (*http.Request, error) {
+			req, err := http.NewRequest(method, "http://"+upstreamHost, body)
+			if err != nil {
+				return nil, err
+			}
+			req.Host = upstreamHost
+			return req, nil
+		},
+	}
+}
+
+func serveFakeProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		resp := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+		if _, err := conn.Write([]byte(resp)); err != nil {
+			return
+		}
+	}
+}
+
+func TestManagerCloseAll(t *testing.T) {
+	m := NewManager(testConfig())
+
+	conns := make([]*Conn, 0, 5)
+	for i := 0; i < 5; i++ {
+		conn, err := m.NewConn(fmt.Sprintf("dest%d.example.com:80", i))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, conn.Connect()) {
+			t.FailNow()
+		}
+		conns = append(conns, conn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, m.CloseAll(ctx))
+
+	// New dials should still succeed since CloseAll doesn't seal the Manager.
+	_, err := m.NewConn("dest-after-close.example.com:80")
+	assert.NoError(t, err, "CloseAll should not prevent subsequent dials")
+
+	m.Seal()
+	_, err = m.NewConn("dest-after-seal.example.com:80")
+	assert.Error(t, err, "dialing after Seal should fail")
+}
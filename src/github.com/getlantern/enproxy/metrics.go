@@ -0,0 +1,59 @@
+package enproxy
+
+import "time"
+
+// Metrics is a single interface an app can implement to observe a Conn's
+// full request lifecycle, as an alternative to wiring up the individual
+// OnXxx callbacks (OnRedial, OnClose, OnRateLimited, ...) one at a time.
+// It's purely additive: every method here fires alongside, not instead
+// of, whichever of those callbacks are also set, so adopting Metrics
+// doesn't require tearing out existing instrumentation. Embed NoopMetrics
+// to satisfy this interface without implementing every method -- most
+// implementations (a Prometheus or OTel adapter, say) only care about a
+// couple of these and want the rest to cost nothing.
+type Metrics interface {
+	// RequestStarted is called once per doRequest call, before its first
+	// attempt at op, regardless of how many transport-level retries that
+	// attempt ends up taking.
+	RequestStarted(op string)
+	// RequestFinished is called once doRequest returns: err is nil on
+	// success, in which case bytes is the response's declared
+	// Content-Length (0 if chunked or otherwise not declared); non-nil on
+	// failure, in which case bytes is always 0. dur covers every retry
+	// doRequest made, not just the final attempt.
+	RequestFinished(op string, bytes int, dur time.Duration, err error)
+	// Redial is called every time doRequest redials after a transport
+	// failure; see RedialEvent.Cause for the same event in Config.OnRedial
+	// callback form, which carries more detail than just the error.
+	Redial(cause error)
+	// Connected is called once Connect succeeds, with the timings
+	// recorded establishing the tunnel.
+	Connected(timings Timings)
+	// Closed is called once a Conn finishes closing, with the reason it
+	// closed and its final stats; see Config.OnClose for the same event
+	// in callback form.
+	Closed(reason Reason, stats ConnStats)
+	// ConnReused is called once per doRequest attempt, right after the
+	// underlying proxy connection for op is in hand, with warm true if
+	// that connection was already established and reused from a previous
+	// request and false if this attempt just dialed it fresh. This
+	// package doesn't measure round-trip time anywhere, so there's no RTT
+	// to tag with warm/cold the way a caller expecting an ObserveRTT hook
+	// might want; ConnReused is the real, directly observable signal this
+	// package has for distinguishing the two, on the theory that a cold
+	// connection's first request is the one most likely to see an outlier
+	// latency worth attributing separately.
+	ConnReused(op string, warm bool)
+}
+
+// NoopMetrics implements Metrics with methods that do nothing. Embed it in
+// a partial implementation to pick up no-op defaults for whichever methods
+// aren't overridden.
+type NoopMetrics struct{}
+
+func (NoopMetrics) RequestStarted(op string)                                           {}
+func (NoopMetrics) RequestFinished(op string, bytes int, dur time.Duration, err error) {}
+func (NoopMetrics) Redial(cause error)                                                 {}
+func (NoopMetrics) Connected(timings Timings)                                          {}
+func (NoopMetrics) Closed(reason Reason, stats ConnStats)                              {}
+func (NoopMetrics) ConnReused(op string, warm bool)                                    {}
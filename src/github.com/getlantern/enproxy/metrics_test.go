@@ -0,0 +1,194 @@
+package enproxy
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics implements Metrics by recording every call it receives,
+// for tests to assert against.
+type recordingMetrics struct {
+	started  []string
+	finished []finishedCall
+	redials  []error
+	connects []Timings
+	closes   []Reason
+	reuses   []reuseCall
+}
+
+type reuseCall struct {
+	op   string
+	warm bool
+}
+
+type finishedCall struct {
+	op    string
+	bytes int
+	err   error
+}
+
+func (m *recordingMetrics) RequestStarted(op string) {
+	m.started = append(m.started, op)
+}
+
+func (m *recordingMetrics) RequestFinished(op string, bytes int, dur time.Duration, err error) {
+	m.finished = append(m.finished, finishedCall{op: op, bytes: bytes, err: err})
+}
+
+func (m *recordingMetrics) Redial(cause error) {
+	m.redials = append(m.redials, cause)
+}
+
+func (m *recordingMetrics) Connected(timings Timings) {
+	m.connects = append(m.connects, timings)
+}
+
+func (m *recordingMetrics) Closed(reason Reason, stats ConnStats) {
+	m.closes = append(m.closes, reason)
+}
+
+func (m *recordingMetrics) ConnReused(op string, warm bool) {
+	m.reuses = append(m.reuses, reuseCall{op: op, warm: warm})
+}
+
+// TestMetricsRequestLifecycle confirms RequestStarted/RequestFinished fire
+// once per doRequest call, with the declared Content-Length on success and
+// the failure on error.
+func TestMetricsRequestLifecycle(t *testing.T) {
+	metrics := &recordingMetrics{}
+	cfg := testConfig()
+	cfg.Metrics = metrics
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	if !assert.Equal(t, []string{opConnect}, metrics.started) {
+		t.FailNow()
+	}
+	if !assert.Equal(t, 1, len(metrics.finished)) {
+		t.FailNow()
+	}
+	assert.Equal(t, opConnect, metrics.finished[0].op)
+	assert.NoError(t, metrics.finished[0].err)
+
+	assert.Equal(t, 1, len(metrics.connects), "Connected should fire once Connect succeeds")
+
+	noDial := errors.New("dial refused")
+	cfg2 := testConfig()
+	metrics2 := &recordingMetrics{}
+	cfg2.Metrics = metrics2
+	cfg2.DialProxy = func(addr string) (net.Conn, error) { return nil, noDial }
+	failConn := cfg2.NewConn("dest.example.com:80")
+	_, err := failConn.doRequest(opConnect, nil)
+	assert.Error(t, err)
+	if !assert.Equal(t, 1, len(metrics2.finished)) {
+		t.FailNow()
+	}
+	assert.Equal(t, 0, metrics2.finished[0].bytes)
+	assert.Error(t, metrics2.finished[0].err)
+}
+
+// TestMetricsRedial confirms Redial fires alongside Config.OnRedial on a
+// transport-retry.
+func TestMetricsRedial(t *testing.T) {
+	var failDials int32
+	metrics := &recordingMetrics{}
+	cfg := testConfig()
+	cfg.AutoReconnect = true
+	cfg.Metrics = metrics
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		if atomic.LoadInt32(&failDials) > 0 {
+			atomic.AddInt32(&failDials, -1)
+			return nil, assert.AnError
+		}
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	atomic.StoreInt32(&failDials, 2)
+	conn.closeProxyConn()
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 2, len(metrics.redials))
+}
+
+// TestMetricsConnReused confirms ConnReused reports false for the request
+// that dials the proxy connection and true for every later request that
+// reuses it, until a redial forces a fresh dial again.
+func TestMetricsConnReused(t *testing.T) {
+	metrics := &recordingMetrics{}
+	cfg := testConfig()
+	cfg.Metrics = metrics
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	conn.closeProxyConn()
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	if !assert.Equal(t, 3, len(metrics.reuses)) {
+		t.FailNow()
+	}
+	assert.Equal(t, reuseCall{op: opConnect, warm: false}, metrics.reuses[0])
+	assert.Equal(t, reuseCall{op: opWrite, warm: true}, metrics.reuses[1])
+	assert.Equal(t, reuseCall{op: opWrite, warm: false}, metrics.reuses[2])
+}
+
+// TestMetricsClosed confirms Closed fires alongside Config.OnClose.
+func TestMetricsClosed(t *testing.T) {
+	metrics := &recordingMetrics{}
+	cfg := testConfig()
+	cfg.Metrics = metrics
+
+	conn := cfg.NewConn("dest.example.com:80")
+	assert.NoError(t, conn.Close())
+
+	if !assert.Equal(t, 1, len(metrics.closes)) {
+		t.FailNow()
+	}
+	assert.Equal(t, ReasonExplicit, metrics.closes[0])
+}
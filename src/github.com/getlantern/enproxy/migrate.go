@@ -0,0 +1,38 @@
+package enproxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+)
+
+// MigrateTo replaces c's current proxy connection with one dialed via
+// selector, closing the old one, so a live tunnel can move off its current
+// proxy (for draining, rebalancing, or routing around a specific failure)
+// without losing the logical connection: every op already carries
+// XEnproxyID, so the proxy recognizes it by id regardless of which socket
+// it arrives on, the same way an ordinary redial does. selector stands in
+// for Config.DialProxy for this one dial only; later redials (an idle
+// timeout, the proxy closing the socket, and so on) go back through
+// Config.DialProxy as usual.
+func (c *Conn) MigrateTo(selector func() (net.Conn, error)) error {
+	conn, err := selector()
+	if err != nil {
+		return fmt.Errorf("Unable to dial migration target: %v", err)
+	}
+	conn, err = c.setUpDialedConn(conn)
+	if err != nil {
+		return err
+	}
+
+	c.connInfoMutex.Lock()
+	old := c.ci
+	c.ci = &connInfo{conn: conn, reader: bufio.NewReader(conn)}
+	c.connInfoMutex.Unlock()
+	c.markActive()
+
+	if old != nil {
+		_ = old.conn.Close()
+	}
+	return nil
+}
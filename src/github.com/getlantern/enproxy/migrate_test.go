@@ -0,0 +1,64 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// namedIDCapturingProxy is like idCapturingProxy but tags each id it sees
+// with a member name, so a test can tell which proxy handled which request.
+func namedIDCapturingProxy(conn net.Conn, name string, idsCh chan<- string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		idsCh <- name + ":" + req.Header.Get(XEnproxyID)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestMigrateToSwitchesUnderlyingConnectionButKeepsID(t *testing.T) {
+	eventsCh := make(chan string, 4)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go namedIDCapturingProxy(server, "original", eventsCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	original := <-eventsCh
+
+	err = conn.MigrateTo(func() (net.Conn, error) {
+		client, server := net.Pipe()
+		go namedIDCapturingProxy(server, "migrated", eventsCh)
+		return client, nil
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	migrated := <-eventsCh
+
+	assert.Equal(t, "original:"+conn.id, original)
+	assert.Equal(t, "migrated:"+conn.id, migrated)
+}
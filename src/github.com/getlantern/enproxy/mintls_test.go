@@ -0,0 +1,97 @@
+package enproxy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// selfSignedCert generates a throwaway self-signed certificate for
+// proxy.example.com, good enough to drive a TLS handshake far enough to
+// observe a version mismatch -- it's never actually trusted, since the
+// version alert happens before the client gets to certificate validation.
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "proxy.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"proxy.example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
+
+// TestMinTLSVersionDefaultsToTLS12 confirms an unconfigured Conn floors the
+// proxy handshake at TLS 1.2 rather than whatever crypto/tls's own default
+// minimum happens to be.
+func TestMinTLSVersionDefaultsToTLS12(t *testing.T) {
+	cfg := testConfig()
+	cfg.TLS = true
+	conn := cfg.NewConn("dest.example.com:80")
+
+	assert.EqualValues(t, tls.VersionTLS12, conn.minTLSVersion())
+	assert.EqualValues(t, tls.VersionTLS12, conn.tlsConfig().MinVersion)
+}
+
+// TestMinTLSVersionHonorsOverride confirms Config.MinTLSVersion, when set,
+// overrides the default floor.
+func TestMinTLSVersionHonorsOverride(t *testing.T) {
+	cfg := testConfig()
+	cfg.TLS = true
+	cfg.MinTLSVersion = tls.VersionTLS13
+	conn := cfg.NewConn("dest.example.com:80")
+
+	assert.EqualValues(t, tls.VersionTLS13, conn.minTLSVersion())
+	assert.EqualValues(t, tls.VersionTLS13, conn.tlsConfig().MinVersion)
+}
+
+// TestTLSHandshakeRejectsBelowMinTLSVersion confirms that when the proxy
+// can't meet Config.MinTLSVersion, the handshake fails with a clear error
+// rather than silently downgrading.
+func TestTLSHandshakeRejectsBelowMinTLSVersion(t *testing.T) {
+	cert := selfSignedCert(t)
+	client, server := net.Pipe()
+	defer func() { _ = server.Close() }()
+
+	serverErrCh := make(chan error, 1)
+	go func() {
+		serverConn := tls.Server(server, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MaxVersion:   tls.VersionTLS12,
+		})
+		serverErrCh <- serverConn.Handshake()
+	}()
+
+	cfg := testConfig()
+	cfg.TLS = true
+	cfg.MinTLSVersion = tls.VersionTLS13
+	conn := cfg.NewConn("dest.example.com:80")
+
+	_, err := conn.maybeWrapTLS(client)
+	assert.Error(t, err, "handshake should fail when the proxy can't meet MinTLSVersion")
+
+	select {
+	case <-serverErrCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server side of the handshake never completed")
+	}
+}
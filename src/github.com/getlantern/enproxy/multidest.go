@@ -0,0 +1,76 @@
+package enproxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// XEnproxyAltDestAddrs carries a comma-separated list of destination
+// addresses to try, in order, after the primary XEnproxyDestAddr, for
+// application-transparent destination failover. Only meaningful on a
+// connect request.
+const XEnproxyAltDestAddrs = "X-Enproxy-Alt-Dest-Addrs"
+
+// XEnproxyChosenDestAddr carries, in a connect response, whichever of the
+// requested destination addresses the proxy actually connected to.
+const XEnproxyChosenDestAddr = "X-Enproxy-Chosen-Dest-Addr"
+
+// addr implements net.Addr for an enproxy destination address, which is
+// always a "host:port" string reached over TCP as far as the application is
+// concerned, regardless of how the proxy itself reaches it.
+type addr string
+
+func (a addr) Network() string { return "tcp" }
+func (a addr) String() string  { return string(a) }
+
+// DialMulti creates a Conn that offers the proxy a list of destination
+// addresses to try in order, for high-availability destinations where the
+// primary may be unreachable. The proxy dials them in order and reports
+// back which one it used; once connected, RemoteAddr reflects that choice.
+// At least one address is required.
+func DialMulti(addrs []string, cfg Config) (*Conn, error) {
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("DialMulti requires at least one address")
+	}
+	conn := cfg.NewConn(addrs[0])
+	conn.altAddrs = addrs[1:]
+	if err := conn.Connect(); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// RemoteAddr returns the destination address this Conn is tunneling to. For
+// a Conn created via DialMulti, this reflects whichever of the offered
+// addresses the proxy actually chose, once Connect has completed; before
+// that, or for a Conn with a single destination, it's just the address that
+// was dialed.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	if c.chosenAddr != "" {
+		return addr(c.chosenAddr)
+	}
+	return addr(c.addr)
+}
+
+// setAltDestHeaders adds the alternate destination addresses, if any, to a
+// connect request.
+func (c *Conn) setAltDestHeaders(op string, header headerSetter) {
+	if op == opConnect && len(c.altAddrs) > 0 {
+		header.Set(XEnproxyAltDestAddrs, strings.Join(c.altAddrs, ","))
+	}
+}
+
+// recordChosenDest captures which destination address the proxy reports
+// having connected to, if offered a choice.
+func (c *Conn) recordChosenDest(header headerGetter) {
+	chosen := header.Get(XEnproxyChosenDestAddr)
+	if chosen == "" {
+		return
+	}
+	c.connInfoMutex.Lock()
+	c.chosenAddr = chosen
+	c.connInfoMutex.Unlock()
+}
@@ -0,0 +1,57 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failoverProxy acknowledges a connect request, reporting back the second
+// of the offered alt addresses as the one it chose.
+func failoverProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+
+	alts := req.Header.Get(XEnproxyAltDestAddrs)
+	chosen := req.Header.Get(XEnproxyDestAddr)
+	if alts != "" {
+		chosen = alts // pretend the primary was unreachable
+	}
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\n" + XEnproxyChosenDestAddr + ": " + chosen + "\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestDialMultiReportsChosenDest(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go failoverProxy(server)
+		return client, nil
+	}
+
+	conn, err := DialMulti([]string{"primary.example.com:80", "backup.example.com:80"}, cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "backup.example.com:80", conn.RemoteAddr().String())
+}
+
+// TestDialMultiRequiresAtLeastOneAddress confirms an empty or nil address
+// list returns an error instead of panicking on addrs[0].
+func TestDialMultiRequiresAtLeastOneAddress(t *testing.T) {
+	cfg := testConfig()
+
+	_, err := DialMulti(nil, cfg)
+	assert.Error(t, err)
+
+	_, err = DialMulti([]string{}, cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,33 @@
+package enproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConnectDegradesGracefullyOnNonTCPDialProxy confirms that, end to end
+// through Connect (not just the individual unit tests in buffersize_test.go
+// and linger_test.go), TCP-specific socket options applied during
+// setUpDialedConn don't prevent establishing a tunnel over a DialProxy that
+// returns something other than a *net.TCPConn, such as the net.Pipe used
+// here or, in practice, a TLS conn, a pipe, or a KCP conn.
+func TestConnectDegradesGracefullyOnNonTCPDialProxy(t *testing.T) {
+	linger := time.Duration(0)
+	cfg := testConfig()
+	cfg.ProxySocketLinger = &linger
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Buffer sizes are applied explicitly via SetReadBuffer/SetWriteBuffer
+	// rather than through Config, and those do return a clear error for a
+	// non-TCP conn rather than silently no-opping, since the caller asked
+	// for a specific guarantee they won't get.
+	assert.Error(t, conn.SetReadBuffer(64*1024))
+	assert.Error(t, conn.SetWriteBuffer(64*1024))
+}
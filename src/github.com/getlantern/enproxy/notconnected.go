@@ -0,0 +1,30 @@
+package enproxy
+
+import "sync/atomic"
+
+// NotConnected is returned from Write or Read when called on a Conn whose
+// connect op has never completed successfully. Dial and DialMulti already
+// block until Connect finishes, so this only happens to an app that
+// constructs a Conn directly via Config.NewConn and calls Write or Read
+// before calling Connect itself -- there's no asynchronous variant of Dial
+// where it could arise otherwise, and no connect-with-first-write
+// optimization that would make it meaningful to queue the write instead.
+type NotConnected struct{}
+
+func (e *NotConnected) Error() string {
+	return "the connect op has not completed successfully on this Conn"
+}
+
+// markConnected records that a connect op has completed successfully, so
+// subsequent Write and Read calls know not to race ahead of it. It's set
+// from doRequest rather than from Connect itself so that tests (and any
+// other caller) that drive the connect op directly through doRequest,
+// bypassing Connect's bookkeeping, still unblock Write and Read correctly.
+func (c *Conn) markConnected() {
+	atomic.StoreInt32(&c.connected, 1)
+}
+
+// isConnected reports whether a connect op has completed successfully on c.
+func (c *Conn) isConnected() bool {
+	return atomic.LoadInt32(&c.connected) == 1
+}
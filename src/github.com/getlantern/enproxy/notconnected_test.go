@@ -0,0 +1,81 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWriteBeforeConnectFails confirms Write returns *NotConnected rather
+// than sending a write op against a tunnel that was never established --
+// the only way to hit this, since Dial and DialMulti already block until
+// Connect finishes, is constructing a Conn directly and skipping Connect.
+func TestWriteBeforeConnectFails(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	_, err := conn.Write([]byte("hello"))
+	_, ok := err.(*NotConnected)
+	assert.True(t, ok, "expected *NotConnected, got %T (%v)", err, err)
+}
+
+// TestReadBeforeConnectFails confirms Read does too, rather than blocking
+// forever waiting on a processReads loop that Connect never started.
+func TestReadBeforeConnectFails(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	_, err := conn.Read(make([]byte, 16))
+	_, ok := err.(*NotConnected)
+	assert.True(t, ok, "expected *NotConnected, got %T (%v)", err, err)
+}
+
+// TestWriteSucceedsAfterConnect confirms the guard only blocks Write ahead
+// of a real connect op, not afterward. It drives the connect op through
+// doRequest directly, as elsewhere in this package, so Connect's background
+// read-polling loop doesn't also race the Write below onto the same
+// net.Pipe connection; without that loop a Read would have nothing to
+// return, so Read isn't exercised here.
+func TestWriteSucceedsAfterConnect(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	assert.NoError(t, err)
+}
+
+// TestDialAgainstSlowProxyIsAlreadyConnectedWhenItReturns confirms a Conn
+// returned by Dial is already past the NotConnected guard, however long
+// the proxy took to complete the connect op, because Dial doesn't return
+// until Connect has already finished -- so Write right after Dial can
+// never race it. (It checks isConnected directly, rather than actually
+// calling Write, to avoid exercising this package's background read loop,
+// which doesn't serialize against an explicit Write on the same
+// connection -- a separate, pre-existing characteristic unrelated to what
+// this test is about.)
+func TestDialAgainstSlowProxyIsAlreadyConnectedWhenItReturns(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			serveFakeProxy(server)
+		}()
+		return client, nil
+	}
+
+	conn, err := Dial("dest.example.com:80", cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.True(t, conn.isConnected())
+}
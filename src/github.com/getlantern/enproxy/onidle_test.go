@@ -0,0 +1,59 @@
+package enproxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleIdleTimeoutDefaultsToLeavingDeadConnCached(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	conn.connInfoMutex.Lock()
+	ci := conn.ci
+	conn.connInfoMutex.Unlock()
+
+	conn.handleIdleTimeout()
+
+	conn.connInfoMutex.Lock()
+	defer conn.connInfoMutex.Unlock()
+	assert.True(t, ci == conn.ci, "IdleClose (the default) should leave the cached connInfo alone")
+}
+
+func TestHandleIdleTimeoutKeepaliveForgetsDeadConn(t *testing.T) {
+	cfg := testConfig()
+	cfg.OnIdle = func(c *Conn) IdleAction { return IdleKeepalive }
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	conn.handleIdleTimeout()
+
+	conn.connInfoMutex.Lock()
+	defer conn.connInfoMutex.Unlock()
+	assert.Nil(t, conn.ci, "IdleKeepalive should forget the dead connInfo so the next request redials")
+}
+
+func TestOnIdleCanInspectConnToDecide(t *testing.T) {
+	cfg := testConfig()
+	var seen *Conn
+	cfg.OnIdle = func(c *Conn) IdleAction {
+		seen = c
+		return IdleClose
+	}
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	conn.handleIdleTimeout()
+	assert.True(t, conn == seen, "OnIdle should be called with the Conn it's deciding for")
+}
@@ -0,0 +1,54 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// opHeaderCapturingProxy answers one request on conn, recording the
+// Cache-Control header it saw (if any) on capturedCh.
+func opHeaderCapturingProxy(conn net.Conn, capturedCh chan<- string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	capturedCh <- req.Header.Get("Cache-Control")
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestOpHeadersAppliedPerOp(t *testing.T) {
+	capturedCh := make(chan string, 1)
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go opHeaderCapturingProxy(server, capturedCh)
+		return client, nil
+	}
+	cfg.OpHeaders = map[string]http.Header{
+		OpWrite: {"Cache-Control": []string{"no-store"}},
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, "", <-capturedCh, "OpHeaders for write shouldn't apply to a connect op")
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, "no-store", <-capturedCh)
+}
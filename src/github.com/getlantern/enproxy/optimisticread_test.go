@@ -0,0 +1,126 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bannerOnConnectProxy answers a connect op by echoing back banner as the
+// response body if and only if the request carries
+// XEnproxyOptimisticReadWindow, simulating a proxy that implements the
+// header. It then answers every subsequent read op with an empty body, so a
+// test can tell whether Read got its data from the prefetched connect body
+// or had to wait for a real read op.
+func bannerOnConnectProxy(conn net.Conn, banner []byte) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+
+		if req.Header.Get(XEnproxyOptimisticReadWindow) != "" {
+			resp := "HTTP/1.1 200 OK\r\nContent-Length: " + strconv.Itoa(len(banner)) + "\r\n\r\n"
+			if _, err := conn.Write([]byte(resp)); err != nil {
+				return
+			}
+			if _, err := conn.Write(banner); err != nil {
+				return
+			}
+			continue
+		}
+
+		if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func TestOptimisticReadWindowBuffersConnectResponseBodyForFirstRead(t *testing.T) {
+	banner := []byte("220 ftp.example.com ready\r\n")
+
+	cfg := testConfig()
+	cfg.OptimisticReadWindow = len(banner)
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go bannerOnConnectProxy(server, banner)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.Equal(t, banner, conn.readBuf, "prefetched bytes should already be buffered before any Read call")
+
+	buf := make([]byte, len(banner))
+	n, err := conn.Read(buf)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, banner, buf[:n])
+}
+
+// TestOptimisticReadWindowKeepsPartialBannerOnTruncatedBody confirms a
+// banner that arrived intact is still buffered for the first Read even if
+// the connect response's declared Content-Length overstates what the proxy
+// connection actually delivered before dropping -- the truncation itself
+// still surfaces, just on whatever op comes next rather than discarding the
+// banner that did make it through.
+func TestOptimisticReadWindowKeepsPartialBannerOnTruncatedBody(t *testing.T) {
+	banner := []byte("220 ftp.example.com ready\r\n")
+
+	cfg := testConfig()
+	cfg.OptimisticReadWindow = len(banner) + 16
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer func() { _ = server.Close() }()
+			reader := bufio.NewReader(server)
+			req, err := http.ReadRequest(reader)
+			if err != nil {
+				return
+			}
+			_, _ = io.Copy(io.Discard, req.Body)
+			resp := "HTTP/1.1 200 OK\r\nContent-Length: " + strconv.Itoa(len(banner)+8) + "\r\n\r\n"
+			if _, err := server.Write([]byte(resp)); err != nil {
+				return
+			}
+			_, _ = server.Write(banner)
+			// The connection drops here, short of the declared
+			// Content-Length, instead of sending the remaining bytes.
+		}()
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.Equal(t, banner, conn.readBuf, "the banner that did arrive should still be buffered despite the truncated body")
+}
+
+func TestOptimisticReadWindowDoesNothingWhenProxyIgnoresHeader(t *testing.T) {
+	cfg := testConfig()
+	cfg.OptimisticReadWindow = 64
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.Empty(t, conn.readBuf, "a proxy that doesn't understand the header shouldn't leave anything buffered")
+}
@@ -0,0 +1,109 @@
+package enproxy
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// payloadAEAD builds the AES-GCM cipher used to seal and open request and
+// response bodies under Config.PayloadKey. It's built fresh on every call
+// rather than cached on Conn, since the cost is negligible next to a round
+// trip to the proxy and it avoids holding key material in more places than
+// necessary.
+func (c *Conn) payloadAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(c.PayloadKey)
+	if err != nil {
+		return nil, fmt.Errorf("PayloadKey must be a valid AES-128/192/256 key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptPayload seals plaintext under a fresh random nonce, returning
+// nonce||ciphertext so the far end can recover the nonce it needs to open
+// it without any out-of-band coordination.
+func (c *Conn) encryptPayload(plaintext []byte) ([]byte, error) {
+	aead, err := c.payloadAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("Unable to generate nonce: %v", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decryptPayload reverses encryptPayload, rejecting data that's been
+// truncated or tampered with since the sender sealed it.
+func (c *Conn) decryptPayload(data []byte) ([]byte, error) {
+	aead, err := c.payloadAEAD()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted payload too short to contain a nonce")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted payload failed integrity check: %v", err)
+	}
+	return plaintext, nil
+}
+
+// encryptRequestBody seals body's entire contents when Config.PayloadKey is
+// set, returning body unchanged otherwise.
+func (c *Conn) encryptRequestBody(body io.Reader) (io.Reader, error) {
+	if c.PayloadKey == nil || body == nil {
+		return body, nil
+	}
+	plaintext, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read request body for encryption: %v", err)
+	}
+	ciphertext, err := c.encryptPayload(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to encrypt request body: %v", err)
+	}
+	return bytes.NewReader(ciphertext), nil
+}
+
+// decryptResponseBody replaces resp.Body with its opened contents when
+// Config.PayloadKey is set, fully draining and closing the original body
+// (which may itself be wrapped, for example to close the underlying proxy
+// connection once read) in the process. It also corrects resp.ContentLength,
+// which otherwise still reflects the ciphertext's on-wire length (longer
+// than the plaintext by a nonce and AEAD tag), causing processReads to flag
+// the swap as a *TruncatedResponse once the real, shorter body is drained.
+// It's a no-op when PayloadKey is unset.
+func (c *Conn) decryptResponseBody(resp *http.Response) error {
+	if c.PayloadKey == nil {
+		return nil
+	}
+	ciphertext, readErr := ioutil.ReadAll(resp.Body)
+	closeErr := resp.Body.Close()
+	if readErr != nil {
+		return fmt.Errorf("Unable to read response body for decryption: %v", readErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("Unable to close response body: %v", closeErr)
+	}
+	if len(ciphertext) == 0 {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(nil))
+		resp.ContentLength = 0
+		return nil
+	}
+	plaintext, err := c.decryptPayload(ciphertext)
+	if err != nil {
+		return fmt.Errorf("Unable to decrypt response body: %v", err)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(plaintext))
+	resp.ContentLength = int64(len(plaintext))
+	return nil
+}
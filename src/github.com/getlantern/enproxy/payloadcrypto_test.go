@@ -0,0 +1,212 @@
+package enproxy
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testPayloadKey = []byte("0123456789abcdef0123456789abcdef")[:32]
+
+// echoBodyProxy answers every request by echoing back the exact bytes of
+// its body, capturing the first request's raw (still encrypted) body into
+// capturedCiphertext so a test can assert it never contains the plaintext.
+func echoBodyProxy(conn net.Conn, capturedCiphertext *[]byte) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		if *capturedCiphertext == nil {
+			*capturedCiphertext = body
+		}
+		resp := "HTTP/1.1 200 OK\r\nContent-Length: " +
+			strconv.Itoa(len(body)) + "\r\n\r\n"
+		if _, err := conn.Write([]byte(resp)); err != nil {
+			return
+		}
+		if _, err := conn.Write(body); err != nil {
+			return
+		}
+	}
+}
+
+// storeAndForwardProxy answers the connect op normally, then stores the raw
+// (still encrypted, if PayloadKey is set) body of each write op and echoes
+// it back as the body of the next read op, simulating a destination that
+// returns whatever was last sent to it.
+func storeAndForwardProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	var lastBody []byte
+	for {
+		req, err = http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		if req.Header.Get(XEnproxyOp) == opWrite {
+			lastBody = body
+			if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")); err != nil {
+				return
+			}
+			continue
+		}
+		resp := "HTTP/1.1 200 OK\r\nContent-Length: " + strconv.Itoa(len(lastBody)) + "\r\n\r\n"
+		if _, err := conn.Write([]byte(resp)); err != nil {
+			return
+		}
+		if len(lastBody) > 0 {
+			if _, err := conn.Write(lastBody); err != nil {
+				return
+			}
+		}
+		lastBody = nil
+	}
+}
+
+// TestPayloadKeyRoundTripsOverFullReadWriteCycle drives two full
+// Connect/Write/Read cycles through processReads, rather than a raw
+// doRequest call that discards its response: a regression test for
+// processReads flagging a decrypted read op as a *TruncatedResponse, since
+// decryptResponseBody used to leave resp.ContentLength at the ciphertext's
+// (longer) wire length after replacing resp.Body with the shorter plaintext.
+// The bug let the first round trip succeed -- the plaintext had already been
+// delivered to Read by the time processReads got around to checking it --
+// and only surfaced on the very next Read, once processReads had torn the
+// connection down out from under it.
+func TestPayloadKeyRoundTripsOverFullReadWriteCycle(t *testing.T) {
+	cfg := testConfig()
+	cfg.PayloadKey = testPayloadKey
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go storeAndForwardProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	for _, payload := range [][]byte{
+		[]byte("hello through an encrypted read/write cycle"),
+		[]byte("a second payload on the same Conn"),
+	} {
+		_, err := conn.Write(payload)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		b := make([]byte, len(payload))
+		n, err := io.ReadFull(conn, b)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, payload, b[:n])
+	}
+}
+
+func TestPayloadKeyRoundTripsAndHidesPlaintextOnWire(t *testing.T) {
+	var ciphertext []byte
+	cfg := testConfig()
+	cfg.PayloadKey = testPayloadKey
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go echoBodyProxy(server, &ciphertext)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	plaintext := []byte("secret-write-payload")
+	resp, err := conn.doRequest(opWrite, bytes.NewReader(plaintext))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.NotContains(t, string(ciphertext), "secret-write-payload", "plaintext should never appear on the wire")
+
+	echoed, err := io.ReadAll(resp.Body)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, plaintext, echoed, "decrypted response body should match what was originally written")
+}
+
+func TestPayloadKeyDetectsTamperedResponseBody(t *testing.T) {
+	cfg := testConfig()
+	cfg.PayloadKey = testPayloadKey
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer func() { _ = server.Close() }()
+			reader := bufio.NewReader(server)
+			req, err := http.ReadRequest(reader)
+			if err != nil {
+				return
+			}
+			body, _ := io.ReadAll(req.Body)
+			if len(body) > 0 {
+				body[len(body)-1] ^= 0xFF
+			}
+			resp := "HTTP/1.1 200 OK\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n"
+			_, _ = server.Write([]byte(resp))
+			_, _ = server.Write(body)
+		}()
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opWrite, bytes.NewReader([]byte("hello")))
+	assert.Error(t, err, "a tampered ciphertext should fail AEAD integrity verification")
+}
+
+func TestPayloadKeyRejectsResponseSealedWithDifferentKey(t *testing.T) {
+	wrongKeyConn := &Conn{Config: Config{PayloadKey: []byte("ffffffffffffffffffffffffffffffff")[:32]}}
+
+	cfg := testConfig()
+	cfg.PayloadKey = testPayloadKey
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer func() { _ = server.Close() }()
+			reader := bufio.NewReader(server)
+			req, err := http.ReadRequest(reader)
+			if err != nil {
+				return
+			}
+			_, _ = io.Copy(io.Discard, req.Body)
+			ciphertext, err := wrongKeyConn.encryptPayload([]byte("not for you"))
+			if err != nil {
+				return
+			}
+			resp := "HTTP/1.1 200 OK\r\nContent-Length: " + strconv.Itoa(len(ciphertext)) + "\r\n\r\n"
+			_, _ = server.Write([]byte(resp))
+			_, _ = server.Write(ciphertext)
+		}()
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opWrite, bytes.NewReader([]byte("hello")))
+	assert.Error(t, err, "a response sealed under a different key should fail to decrypt")
+}
@@ -0,0 +1,55 @@
+package enproxy
+
+import "io"
+
+// maxPeek bounds how many bytes Peek will ever buffer ahead of Read, so a
+// caller can't force a Conn to hold arbitrarily much pending response data
+// in memory just by asking to peek further into it.
+const maxPeek = 64 * 1024
+
+// Peek returns up to n bytes of pending response data without consuming
+// it: subsequent Read calls still see every byte Peek returned, plus
+// whatever follows. This supports protocol sniffing (for example reading a
+// TLS ClientHello's SNI) on the client side of the tunnel before deciding
+// how to handle the rest of the stream.
+//
+// n is capped at maxPeek. Peek blocks until either n bytes are available
+// or a read error (including io.EOF) arrives first, in which case it
+// returns whatever was buffered alongside that error; a later Read then
+// returns that same error once the buffered bytes are exhausted. Peek
+// shares Read's readMutex, so a Peek and a concurrent Read (or Peek) from
+// another goroutine simply queue up rather than racing on readBuf.
+func (c *Conn) Peek(n int) ([]byte, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	if n > maxPeek {
+		n = maxPeek
+	}
+
+	for len(c.readBuf) < n && c.peekErr == nil {
+		select {
+		case chunk := <-c.readResponsesCh:
+			c.readBuf = append(c.readBuf, chunk...)
+		case err := <-c.readErrCh:
+			c.peekErr = err
+		case <-c.closeCh:
+			c.peekErr = io.EOF
+		}
+	}
+
+	peeked := n
+	if peeked > len(c.readBuf) {
+		peeked = len(c.readBuf)
+	}
+	result := make([]byte, peeked)
+	copy(result, c.readBuf[:peeked])
+
+	if peeked < n && c.peekErr != nil {
+		return result, c.peekErr
+	}
+	return result, nil
+}
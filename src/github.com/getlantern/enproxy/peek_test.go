@@ -0,0 +1,85 @@
+package enproxy
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeekDoesNotConsumeBytesFromRead(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go eofAfterOneReadProxy(server, "hello")
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	peeked, err := conn.Peek(3)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "hel", string(peeked))
+
+	b := make([]byte, 16)
+	n, err := conn.Read(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "hello", string(b[:n]), "Read should still see every byte Peek returned")
+}
+
+func TestPeekReturnsShortResultAlongsideEOF(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go eofAfterOneReadProxy(server, "hi")
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	peeked, err := conn.Peek(10)
+	assert.Equal(t, "hi", string(peeked))
+	assert.Equal(t, io.EOF, err, "Peek should return the buffered bytes alongside the error that cut it short")
+
+	b := make([]byte, 16)
+	n, err := conn.Read(b)
+	assert.Equal(t, "hi", string(b[:n]))
+	assert.NoError(t, err)
+
+	_, err = conn.Read(b)
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestPeekCapsAtMaxPeek(t *testing.T) {
+	payload := strings.Repeat("a", maxPeek+100)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go eofAfterOneReadProxy(server, payload)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	peeked, err := conn.Peek(maxPeek + 1000)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Len(t, peeked, maxPeek, "Peek should never buffer more than maxPeek bytes ahead")
+}
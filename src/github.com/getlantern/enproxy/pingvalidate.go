@@ -0,0 +1,61 @@
+package enproxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"time"
+)
+
+// defaultValidateBeforeReuseMaxAge is used when
+// Config.ValidateBeforeReuseMaxAge is unset.
+const defaultValidateBeforeReuseMaxAge = 5 * time.Second
+
+// validateBeforeReuseMaxAge returns c.ValidateBeforeReuseMaxAge, or
+// defaultValidateBeforeReuseMaxAge if unset.
+func (c *Conn) validateBeforeReuseMaxAge() time.Duration {
+	if c.ValidateBeforeReuseMaxAge > 0 {
+		return c.ValidateBeforeReuseMaxAge
+	}
+	return defaultValidateBeforeReuseMaxAge
+}
+
+// shouldPingBeforeReuse reports whether a cached connection is old enough
+// that Config.ValidateBeforeReuse should probe it before handing it out
+// again.
+func (c *Conn) shouldPingBeforeReuse() bool {
+	if !c.ValidateBeforeReuse {
+		return false
+	}
+	last := c.lastActivity()
+	return last.IsZero() || time.Since(last) > c.validateBeforeReuseMaxAge()
+}
+
+// pingProxyConn sends a minimal opPing request over conn/reader and
+// confirms a 2xx comes back, as a liveness check for a connection that's
+// been idle long enough to be suspicious. It deliberately skips everything
+// doRequestOnce does beyond what a liveness check needs -- auth headers,
+// body encryption, diagnostics -- since those don't change whether the
+// socket is still good.
+func (c *Conn) pingProxyConn(conn net.Conn, reader *bufio.Reader) error {
+	req, err := c.NewRequest(c.Host, "POST", nil)
+	if err != nil {
+		return fmt.Errorf("Unable to construct ping request: %v", err)
+	}
+	req.Header.Set(XEnproxyID, c.id)
+	req.Header.Set(XEnproxyDestAddr, c.addr)
+	req.Header.Set(XEnproxyOp, opPing)
+
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("Unable to write ping request: %v", err)
+	}
+	resp, err := readFinalResponse(reader, req)
+	if err != nil {
+		return fmt.Errorf("Unable to read ping response: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("ping got unexpected status %v", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateBeforeReuseRedialsOnSilentlyDroppedConn simulates a proxy
+// connection a flaky NAT dropped without either side noticing: the first
+// dial's server answers the connect op and then goes away, so once the
+// connection sits idle past ValidateBeforeReuseMaxAge, the ping probe
+// should fail and force a redial rather than handing the dead connection to
+// the next request.
+func TestValidateBeforeReuseRedialsOnSilentlyDroppedConn(t *testing.T) {
+	dialCount := 0
+
+	cfg := testConfig()
+	cfg.ValidateBeforeReuse = true
+	cfg.ValidateBeforeReuseMaxAge = time.Millisecond
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialCount++
+		client, server := net.Pipe()
+		if dialCount == 1 {
+			go func() {
+				defer func() { _ = server.Close() }()
+				reader := bufio.NewReader(server)
+				req, err := http.ReadRequest(reader)
+				if err != nil {
+					return
+				}
+				_, _ = io.Copy(io.Discard, req.Body)
+				_, _ = server.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+			}()
+		} else {
+			go serveKeepingAlive(server)
+		}
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	time.Sleep(5 * time.Millisecond)
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 2, dialCount, "a dropped connection past its max age should be detected and redialed")
+}
+
+// TestValidateBeforeReuseSkipsFreshConn confirms a connection used more
+// recently than ValidateBeforeReuseMaxAge isn't pinged at all, so the
+// common case pays no extra round trip.
+func TestValidateBeforeReuseSkipsFreshConn(t *testing.T) {
+	dialCount := 0
+	pingCount := 0
+
+	cfg := testConfig()
+	cfg.ValidateBeforeReuse = true
+	cfg.ValidateBeforeReuseMaxAge = time.Hour
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialCount++
+		client, server := net.Pipe()
+		go func() {
+			defer func() { _ = server.Close() }()
+			reader := bufio.NewReader(server)
+			for {
+				req, err := http.ReadRequest(reader)
+				if err != nil {
+					return
+				}
+				_, _ = io.Copy(io.Discard, req.Body)
+				if req.Header.Get(XEnproxyOp) == opPing {
+					pingCount++
+				}
+				_, _ = server.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+			}
+		}()
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 1, dialCount)
+	assert.Equal(t, 0, pingCount, "a connection used well within MaxAge shouldn't be pinged")
+}
@@ -0,0 +1,184 @@
+package enproxy
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultBreakerThreshold is how many consecutive failures a pool member
+// tolerates before it's considered unhealthy and circuit-broken.
+const defaultBreakerThreshold = 3
+
+// defaultBreakerCooldown is how long a circuit-broken member is skipped
+// before it's given another chance.
+const defaultBreakerCooldown = 30 * time.Second
+
+// NoHealthyProxy is returned when a Pool has no member that isn't currently
+// circuit-broken.
+type NoHealthyProxy struct {
+	// Addrs is every member the Pool considered unhealthy.
+	Addrs []string
+}
+
+func (e *NoHealthyProxy) Error() string {
+	return fmt.Sprintf("no healthy proxy among %v", e.Addrs)
+}
+
+type poolMember struct {
+	addr        string
+	failures    int
+	brokenUntil time.Time
+}
+
+// Pool round-robins dials across a fixed set of proxy addresses, tracking
+// each one's health with a simple circuit breaker: a member that fails
+// defaultBreakerThreshold times in a row is skipped for defaultBreakerCooldown
+// before being tried again. It's safe for concurrent use.
+//
+// The zero value is not usable; construct one with NewPool.
+type Pool struct {
+	mu      sync.Mutex
+	members []*poolMember
+	next    int
+}
+
+// NewPool creates a Pool that selects among addrs.
+func NewPool(addrs []string) *Pool {
+	members := make([]*poolMember, len(addrs))
+	for i, addr := range addrs {
+		members[i] = &poolMember{addr: addr}
+	}
+	return &Pool{members: members}
+}
+
+// Select returns the next healthy member in round-robin order, or a
+// *NoHealthyProxy error if every member is currently circuit-broken.
+func (p *Pool) Select() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	all := make([]string, 0, len(p.members))
+	for i := 0; i < len(p.members); i++ {
+		idx := (p.next + i) % len(p.members)
+		m := p.members[idx]
+		all = append(all, m.addr)
+		if now.Before(m.brokenUntil) {
+			continue
+		}
+		p.next = (idx + 1) % len(p.members)
+		return m.addr, nil
+	}
+	return "", &NoHealthyProxy{Addrs: all}
+}
+
+// MarkSuccess resets addr's failure count, healing it if it was broken.
+func (p *Pool) MarkSuccess(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m := p.member(addr); m != nil {
+		m.failures = 0
+		m.brokenUntil = time.Time{}
+	}
+}
+
+// MarkFailure records a failed dial or request against addr, circuit
+// breaking it for defaultBreakerCooldown once defaultBreakerThreshold
+// consecutive failures have been seen.
+func (p *Pool) MarkFailure(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := p.member(addr)
+	if m == nil {
+		return
+	}
+	m.failures++
+	if m.failures >= defaultBreakerThreshold {
+		m.brokenUntil = time.Now().Add(defaultBreakerCooldown)
+	}
+}
+
+func (p *Pool) member(addr string) *poolMember {
+	for _, m := range p.members {
+		if m.addr == addr {
+			return m
+		}
+	}
+	return nil
+}
+
+// ProxyStats is a snapshot of one Pool member's health, as seen by the
+// circuit breaker Pool.Select consults. It carries no RTT or throughput
+// figures, since Pool doesn't track either -- see Pool.Stats.
+type ProxyStats struct {
+	// Addr is the member this snapshot is for.
+	Addr string
+	// Failures is the member's current consecutive-failure count, reset
+	// by MarkSuccess.
+	Failures int
+	// Healthy is false while the member is circuit-broken and Select is
+	// skipping it.
+	Healthy bool
+	// BrokenUntil is when a circuit-broken member becomes eligible for
+	// Select again. Zero if the member isn't currently broken.
+	BrokenUntil time.Time
+}
+
+// Stats returns a snapshot of addr's current health, and false if addr
+// isn't a member of p. Safe to call concurrently with Select and the
+// MarkSuccess/MarkFailure calls p.DialProxy makes as redials pick a new
+// member.
+func (p *Pool) Stats(addr string) (ProxyStats, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := p.member(addr)
+	if m == nil {
+		return ProxyStats{}, false
+	}
+	return ProxyStats{
+		Addr:        m.addr,
+		Failures:    m.failures,
+		Healthy:     !time.Now().Before(m.brokenUntil),
+		BrokenUntil: m.brokenUntil,
+	}, true
+}
+
+// AllStats returns a snapshot of every member's current health, in the
+// order addrs was given to NewPool.
+func (p *Pool) AllStats() []ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	stats := make([]ProxyStats, len(p.members))
+	for i, m := range p.members {
+		stats[i] = ProxyStats{
+			Addr:        m.addr,
+			Failures:    m.failures,
+			Healthy:     !now.Before(m.brokenUntil),
+			BrokenUntil: m.brokenUntil,
+		}
+	}
+	return stats
+}
+
+// DialProxy returns a Config.DialProxy function that ignores its addr
+// argument in favor of selecting a healthy member of p and dialing that
+// with dial, marking the outcome back on p. Use this to spread Conns dialed
+// from one Config across a pool of interchangeable proxies.
+func (p *Pool) DialProxy(dial func(addr string) (net.Conn, error)) func(addr string) (net.Conn, error) {
+	return func(_ string) (net.Conn, error) {
+		addr, err := p.Select()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dial(addr)
+		if err != nil {
+			p.MarkFailure(addr)
+			return nil, err
+		}
+		p.MarkSuccess(addr)
+		return conn, nil
+	}
+}
@@ -0,0 +1,90 @@
+package enproxy
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolSkipsBrokenMembers(t *testing.T) {
+	pool := NewPool([]string{"a", "b"})
+	for i := 0; i < defaultBreakerThreshold; i++ {
+		pool.MarkFailure("a")
+	}
+
+	addr, err := pool.Select()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "b", addr)
+}
+
+func TestPoolNoHealthyProxy(t *testing.T) {
+	pool := NewPool([]string{"a"})
+	for i := 0; i < defaultBreakerThreshold; i++ {
+		pool.MarkFailure("a")
+	}
+
+	_, err := pool.Select()
+	_, ok := err.(*NoHealthyProxy)
+	assert.True(t, ok, "expected a *NoHealthyProxy error, got %T: %v", err, err)
+}
+
+func TestFailFastWhenNoHealthy(t *testing.T) {
+	pool := NewPool([]string{"a"})
+	for i := 0; i < defaultBreakerThreshold; i++ {
+		pool.MarkFailure("a")
+	}
+
+	cfg := testConfig()
+	cfg.FailFastWhenNoHealthy = true
+	cfg.DialProxy = pool.DialProxy(func(addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("should never be called")
+	})
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opConnect, nil)
+	_, ok := err.(*NoHealthyProxy)
+	assert.True(t, ok, "expected a *NoHealthyProxy error, got %T: %v", err, err)
+}
+
+func TestPoolStats(t *testing.T) {
+	pool := NewPool([]string{"a", "b"})
+
+	stats, ok := pool.Stats("a")
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.Equal(t, "a", stats.Addr)
+	assert.Equal(t, 0, stats.Failures)
+	assert.True(t, stats.Healthy)
+	assert.True(t, stats.BrokenUntil.IsZero())
+
+	for i := 0; i < defaultBreakerThreshold; i++ {
+		pool.MarkFailure("a")
+	}
+	stats, ok = pool.Stats("a")
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.Equal(t, defaultBreakerThreshold, stats.Failures)
+	assert.False(t, stats.Healthy)
+	assert.False(t, stats.BrokenUntil.IsZero())
+
+	pool.MarkSuccess("a")
+	stats, _ = pool.Stats("a")
+	assert.Equal(t, 0, stats.Failures)
+	assert.True(t, stats.Healthy)
+
+	_, ok = pool.Stats("nonexistent")
+	assert.False(t, ok)
+
+	all := pool.AllStats()
+	if !assert.Equal(t, 2, len(all)) {
+		t.FailNow()
+	}
+	assert.Equal(t, "a", all[0].Addr)
+	assert.Equal(t, "b", all[1].Addr)
+}
@@ -0,0 +1,66 @@
+package enproxy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PowerMode adjusts how aggressively a Conn polls the proxy, trading
+// latency for battery and network efficiency. It can be set once via
+// Config.PowerMode or changed at runtime with Conn.SetPowerMode, for apps
+// that want to react to OS battery/metered-connection callbacks.
+type PowerMode int32
+
+const (
+	// PowerModeNormal polls for reads as soon as the previous poll
+	// completes, minimizing latency.
+	PowerModeNormal PowerMode = iota
+
+	// PowerModeLowPower stretches the delay between read polls by
+	// lowPowerPollDelay, reducing how often the radio/CPU wakes up at the
+	// cost of added latency. Use this when the OS reports low battery or a
+	// metered connection.
+	PowerModeLowPower
+)
+
+// lowPowerPollDelay is how long a Conn in PowerModeLowPower waits between
+// finishing one read poll and issuing the next.
+const lowPowerPollDelay = 2 * time.Second
+
+// SetPowerMode updates c's power mode, taking effect before the next read
+// poll is issued.
+func (c *Conn) SetPowerMode(m PowerMode) {
+	atomic.StoreInt32(&c.powerMode, int32(m))
+}
+
+// powerModeNow returns c's current power mode.
+func (c *Conn) powerModeNow() PowerMode {
+	return PowerMode(atomic.LoadInt32(&c.powerMode))
+}
+
+// pollDelay waits out the delay appropriate to c's current power mode
+// before the caller issues its next read poll, returning early if c is
+// closed in the meantime.
+func (c *Conn) pollDelay() {
+	if c.powerModeNow() != PowerModeLowPower {
+		return
+	}
+	select {
+	case <-time.After(lowPowerPollDelay):
+	case <-c.closeCh:
+	}
+}
+
+// CurrentPollDelay returns the delay c is currently applying between
+// finishing one read poll and issuing the next, given its power mode right
+// now. This package doesn't buffer or batch writes, so there's no flush
+// timeout to report; the read-poll delay is the one per-op timing that
+// actually varies at runtime (via SetPowerMode), so this is what apps and
+// tests wanting to observe that adaptation should read. It's a cheap,
+// thread-safe, non-blocking read of the current setting.
+func (c *Conn) CurrentPollDelay() time.Duration {
+	if c.powerModeNow() != PowerModeLowPower {
+		return 0
+	}
+	return lowPowerPollDelay
+}
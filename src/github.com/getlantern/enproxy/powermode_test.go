@@ -0,0 +1,41 @@
+package enproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetPowerModeDelaysPolling(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	assert.Equal(t, PowerModeNormal, conn.powerModeNow())
+
+	conn.SetPowerMode(PowerModeLowPower)
+	assert.Equal(t, PowerModeLowPower, conn.powerModeNow())
+
+	// Closing should interrupt an in-progress pollDelay rather than making
+	// it wait out the full low power delay.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(conn.closeCh)
+	}()
+	start := time.Now()
+	conn.pollDelay()
+	assert.True(t, time.Since(start) < lowPowerPollDelay, "pollDelay should return early once the Conn is closed")
+}
+
+func TestCurrentPollDelayReflectsPowerMode(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	assert.Equal(t, time.Duration(0), conn.CurrentPollDelay())
+
+	conn.SetPowerMode(PowerModeLowPower)
+	assert.Equal(t, lowPowerPollDelay, conn.CurrentPollDelay())
+
+	conn.SetPowerMode(PowerModeNormal)
+	assert.Equal(t, time.Duration(0), conn.CurrentPollDelay())
+}
@@ -0,0 +1,40 @@
+package enproxy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// quiescePollInterval is how often Quiesce rechecks PendingRequests while
+// waiting for it to reach zero.
+const quiescePollInterval = 5 * time.Millisecond
+
+// errQuiescing is returned by Write once Quiesce has been called, so that
+// no new writes can start a request Quiesce would then have to wait on.
+var errQuiescing = fmt.Errorf("Conn is quiescing, not accepting new writes")
+
+// PendingRequests returns how many Write calls currently have a request
+// in flight to the proxy.
+func (c *Conn) PendingRequests() int {
+	return int(atomic.LoadInt32(&c.pendingWrites))
+}
+
+// Quiesce blocks until PendingRequests reaches zero or ctx expires,
+// refusing any new writes in the meantime so that a coordinated shutdown or
+// rotation doesn't race a confirmation still in flight. It returns ctx's
+// error on timeout. Quiescing is permanent for the life of the Conn; it
+// isn't meant to be undone.
+func (c *Conn) Quiesce(ctx context.Context) error {
+	atomic.StoreInt32(&c.quiescing, 1)
+
+	for c.PendingRequests() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(quiescePollInterval):
+		}
+	}
+	return nil
+}
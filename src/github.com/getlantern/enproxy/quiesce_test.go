@@ -0,0 +1,115 @@
+package enproxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowWriteProxy answers a connect op normally, then holds a write op open
+// until release is closed, so tests can observe PendingRequests while a
+// write is mid-flight.
+func slowWriteProxy(conn net.Conn, release <-chan struct{}) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	<-release
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestQuiesceWaitsForPendingWrites(t *testing.T) {
+	release := make(chan struct{})
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go slowWriteProxy(server, release)
+		return client, nil
+	}
+
+	// doRequest(opConnect, ...) directly, rather than Connect, so there's
+	// no background processReads goroutine issuing its own requests over
+	// the same proxy conn concurrently with the Write below.
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("hi"))
+		writeDone <- err
+	}()
+
+	for conn.PendingRequests() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	quiesceDone := make(chan error, 1)
+	go func() { quiesceDone <- conn.Quiesce(context.Background()) }()
+
+	select {
+	case <-quiesceDone:
+		t.Fatal("Quiesce should still be blocked on the in-flight write")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	_, err = conn.Write([]byte("rejected"))
+	assert.Equal(t, errQuiescing, err, "Quiesce should refuse new writes while waiting")
+
+	close(release)
+	assert.NoError(t, <-writeDone)
+
+	select {
+	case err := <-quiesceDone:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Quiesce should have returned once the pending write finished")
+	}
+}
+
+func TestQuiesceReturnsContextErrorOnTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go slowWriteProxy(server, release)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	go func() { _, _ = conn.Write([]byte("hi")) }()
+	for conn.PendingRequests() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, conn.Quiesce(ctx))
+}
@@ -0,0 +1,63 @@
+package enproxy
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple token-bucket rate limiter used to smooth reads and
+// writes to a configured rate. A zero-value tokenBucket (or a nil *tokenBucket)
+// imposes no limit.
+type tokenBucket struct {
+	bytesPerSecond int64
+
+	mu       sync.Mutex
+	tokens   float64
+	lastTime time.Time
+}
+
+// newTokenBucket creates a tokenBucket allowing up to bytesPerSecond bytes
+// per second, or an unlimited bucket if bytesPerSecond is zero.
+func newTokenBucket(bytesPerSecond int64) *tokenBucket {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		bytesPerSecond: bytesPerSecond,
+		tokens:         float64(bytesPerSecond),
+		lastTime:       time.Now(),
+	}
+}
+
+// take blocks until n bytes' worth of tokens are available, then consumes
+// them. It never blocks on a nil tokenBucket, since a nil bucket means
+// unlimited.
+func (b *tokenBucket) take(n int) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastTime).Seconds()
+	b.lastTime = now
+	b.tokens += elapsed * float64(b.bytesPerSecond)
+	if max := float64(b.bytesPerSecond); b.tokens > max {
+		b.tokens = max
+	}
+
+	need := float64(n)
+	if b.tokens >= need {
+		b.tokens -= need
+		return
+	}
+
+	deficit := need - b.tokens
+	wait := time.Duration(deficit / float64(b.bytesPerSecond) * float64(time.Second))
+	b.tokens = 0
+	b.mu.Unlock()
+	time.Sleep(wait)
+	b.mu.Lock()
+}
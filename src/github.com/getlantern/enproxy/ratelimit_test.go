@@ -0,0 +1,31 @@
+package enproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	var b *tokenBucket
+	start := time.Now()
+	b.take(1 << 20)
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "a nil/zero-rate bucket should never add latency")
+}
+
+func TestTokenBucketSmoothsBursts(t *testing.T) {
+	b := newTokenBucket(1000)
+
+	// The initial burst up to the bucket's capacity should be immediate.
+	start := time.Now()
+	b.take(1000)
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "initial burst within capacity should not be delayed")
+
+	// Asking for another full second's worth with no tokens left should
+	// block for roughly one second.
+	start = time.Now()
+	b.take(1000)
+	elapsed := time.Since(start)
+	assert.True(t, elapsed > 800*time.Millisecond, "should wait for tokens to refill, took %s", elapsed)
+}
@@ -0,0 +1,62 @@
+package enproxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimited is returned when the proxy keeps responding 429 and the
+// cumulative backoff for a single request exceeds Config.MaxRateLimitBackoff.
+//
+// This package is the client half of the enproxy protocol only; it has no
+// proxy/server implementation, so there's nowhere to add a server-side
+// MaxRequestRate token bucket or a 429-emitting handler. RateLimited and
+// Config.MaxRateLimitBackoff/OnRateLimited above are this package's side of
+// that contract: whatever authoritative rate limiting the proxy enforces,
+// this is how a client backs off and eventually gives up in response to it.
+type RateLimited struct {
+	// Attempts is how many failed attempts were seen before giving up: the
+	// 429 responses, plus any transport failures retried in the same call.
+	Attempts int
+	// TotalBackoff is how long this call spent sleeping on account of 429s.
+	TotalBackoff time.Duration
+}
+
+func (e *RateLimited) Error() string {
+	return fmt.Sprintf("proxy rate-limited us %d times (%s total backoff), giving up", e.Attempts, e.TotalBackoff)
+}
+
+// defaultMaxRateLimitBackoff bounds how long a single request will wait on
+// 429s when Config.MaxRateLimitBackoff is unset.
+const defaultMaxRateLimitBackoff = 30 * time.Second
+
+// retryAfter parses a Retry-After header in either of its two permitted
+// forms (a number of seconds, or an HTTP-date), returning 0 if the header is
+// absent or unparseable.
+func retryAfter(resp *http.Response) time.Duration {
+	h := resp.Header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		d := time.Until(t)
+		if d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// maxRateLimitBackoff returns the configured backoff budget, or the package
+// default if unset.
+func (c *Conn) maxRateLimitBackoff() time.Duration {
+	if c.MaxRateLimitBackoff > 0 {
+		return c.MaxRateLimitBackoff
+	}
+	return defaultMaxRateLimitBackoff
+}
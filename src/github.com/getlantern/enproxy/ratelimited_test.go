@@ -0,0 +1,53 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rateLimitedOnceProxy responds 429 with a short Retry-After to the first
+// request it sees and 200 to every subsequent one.
+func rateLimitedOnceProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	first := true
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		if first {
+			first = false
+			_, _ = conn.Write([]byte("HTTP/1.1 429 Too Many Requests\r\nRetry-After: 0\r\nContent-Length: 0\r\n\r\n"))
+			continue
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestDoRequestRetriesAfterRateLimit(t *testing.T) {
+	var sawBackoff bool
+	cfg := testConfig()
+	cfg.OnRateLimited = func(backoff time.Duration) { sawBackoff = true }
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go rateLimitedOnceProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, sawBackoff, "OnRateLimited should have fired for the 429 response")
+}
@@ -0,0 +1,97 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readBatchHeaderCapturingProxy answers every request with an empty 200 OK,
+// recording the XEnproxyReadBatchMaxBytes/XEnproxyReadBatchMaxWait headers
+// off the first read op it sees into the provided pointers -- simulating a
+// proxy that may or may not implement batched reads; either way, all this
+// client-side feature does is send the headers.
+func readBatchHeaderCapturingProxy(conn net.Conn, gotMaxBytes, gotMaxWait *string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	captured := false
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+
+		if !captured && req.Header.Get(XEnproxyOp) == opRead {
+			*gotMaxBytes = req.Header.Get(XEnproxyReadBatchMaxBytes)
+			*gotMaxWait = req.Header.Get(XEnproxyReadBatchMaxWait)
+			captured = true
+		}
+
+		if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func TestReadBatchMaxBytesAndWaitAreSentOnReadRequests(t *testing.T) {
+	var gotMaxBytes, gotMaxWait string
+
+	cfg := testConfig()
+	cfg.ReadBatchMaxBytes = 65536
+	cfg.ReadBatchMaxWait = 200 * time.Millisecond
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go readBatchHeaderCapturingProxy(server, &gotMaxBytes, &gotMaxWait)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	readResp, err := conn.doRequest(opRead, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = readResp.Body.Close()
+
+	assert.Equal(t, "65536", gotMaxBytes)
+	assert.Equal(t, "200", gotMaxWait)
+}
+
+func TestReadBatchMaxWaitHasNoEffectWithoutMaxBytes(t *testing.T) {
+	var gotMaxBytes, gotMaxWait string
+
+	cfg := testConfig()
+	cfg.ReadBatchMaxWait = 200 * time.Millisecond
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go readBatchHeaderCapturingProxy(server, &gotMaxBytes, &gotMaxWait)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	readResp, err := conn.doRequest(opRead, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = readResp.Body.Close()
+
+	assert.Empty(t, gotMaxBytes)
+	assert.Empty(t, gotMaxWait)
+}
@@ -0,0 +1,76 @@
+package enproxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// oversizedFramePayloadProxy accepts the compact framing upgrade, then
+// answers every compact request with a frame whose declared payload length
+// is huge but whose actual payload never arrives, simulating a buggy or
+// malicious proxy trying to make the client over-allocate.
+func oversizedFramePayloadProxy(conn net.Conn, declaredLength uint32) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	if req.Header.Get(compactUpgradeHeader) == "" {
+		return
+	}
+	_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" + compactUpgradeHeader + ": 1\r\n\r\n"))
+
+	if _, _, _, _, err := readCompactRequestFrame(reader); err != nil {
+		return
+	}
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], declaredLength)
+	_, _ = conn.Write(append([]byte{frameStatusOK}, lenBuf[:]...))
+}
+
+func TestMaxReassemblyBytesRejectsOversizedCompactFramePayload(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.MaxReassemblyBytes = 1024
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go oversizedFramePayloadProxy(server, 10*1024*1024)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	assert.True(t, conn.isUpgradedToCompactFraming())
+
+	_, err := conn.doRequest(opRead, nil)
+	assert.Error(t, err, "a declared payload length beyond MaxReassemblyBytes should fail cleanly rather than over-allocate")
+}
+
+func TestMaxReassemblyBytesDefaultsToPackageMaximum(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go oversizedFramePayloadProxy(server, maxCompactFramePayload+1)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	_, err := conn.doRequest(opRead, nil)
+	assert.Error(t, err, "exceeding the default 16MiB cap should still fail cleanly with MaxReassemblyBytes unset")
+}
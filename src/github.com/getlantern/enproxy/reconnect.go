@@ -0,0 +1,44 @@
+package enproxy
+
+import "fmt"
+
+// ReconnectExhausted is returned when Config.AutoReconnect gave up after
+// c.maxReconnectAttempts() reconnect attempts at the same op, following its
+// first transport failure, had all failed too.
+type ReconnectExhausted struct {
+	// Op is the op (opRead or opWrite) that kept failing.
+	Op string
+	// Attempts is how many reconnect attempts were made before giving up.
+	Attempts int
+}
+
+func (e *ReconnectExhausted) Error() string {
+	return fmt.Sprintf("gave up reconnecting after %d attempts at a %s op", e.Attempts, e.Op)
+}
+
+// defaultMaxReconnectAttempts bounds how many times a single Read or Write
+// call will transparently redial and retry when Config.AutoReconnect is set
+// and Config.MaxReconnectAttempts is unset.
+const defaultMaxReconnectAttempts = 5
+
+// maxReconnectAttempts returns the configured reconnect budget, or the
+// package default if unset.
+func (c *Conn) maxReconnectAttempts() int {
+	if c.MaxReconnectAttempts > 0 {
+		return c.MaxReconnectAttempts
+	}
+	return defaultMaxReconnectAttempts
+}
+
+// shouldAutoReconnect is doRequest's default retry decision for transport
+// failures when Config.AutoReconnect is set and Config.ShouldRetry isn't
+// (an explicit ShouldRetry always takes precedence). It only applies to
+// opRead and opWrite: redialing before a tunnel exists (opConnect) or after
+// the app already asked to tear it down (opClose) isn't "reconnecting", so
+// those still fail immediately as they always have.
+func (c *Conn) shouldAutoReconnect(op string, attempt int) bool {
+	if op != opRead && op != opWrite {
+		return false
+	}
+	return attempt <= c.maxReconnectAttempts()
+}
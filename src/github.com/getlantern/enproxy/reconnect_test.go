@@ -0,0 +1,97 @@
+package enproxy
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoReconnectSurvivesTransientDialFailures(t *testing.T) {
+	var dialAttempts int32
+	var failDials int32
+
+	cfg := testConfig()
+	cfg.AutoReconnect = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		atomic.AddInt32(&dialAttempts, 1)
+		if atomic.LoadInt32(&failDials) > 0 {
+			atomic.AddInt32(&failDials, -1)
+			return nil, assert.AnError
+		}
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The connect itself succeeded; now make the next couple of redials
+	// fail transiently and confirm Write still succeeds rather than
+	// surfacing the blip to the caller.
+	atomic.StoreInt32(&failDials, 2)
+	conn.closeProxyConn()
+	n, err := conn.Write([]byte("hello"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 5, n)
+}
+
+func TestAutoReconnectGivesUpAfterBudgetAndClosesWithRedialExhausted(t *testing.T) {
+	var reason Reason
+	closed := make(chan struct{})
+
+	cfg := testConfig()
+	cfg.AutoReconnect = true
+	cfg.MaxReconnectAttempts = 2
+	cfg.OnClose = func(stats ConnStats, r Reason) {
+		reason = r
+		close(closed)
+	}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		return nil, assert.AnError
+	}
+	conn.DialProxy = cfg.DialProxy
+	conn.closeProxyConn()
+
+	_, err := conn.Write([]byte("hello"))
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+
+	<-closed
+	assert.Equal(t, ReasonRedialExhausted, reason)
+}
+
+func TestAutoReconnectDoesNotApplyToConnect(t *testing.T) {
+	var dialAttempts int32
+
+	cfg := testConfig()
+	cfg.AutoReconnect = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		atomic.AddInt32(&dialAttempts, 1)
+		return nil, assert.AnError
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	err := conn.Connect()
+	assert.Error(t, err, "AutoReconnect shouldn't retry a connect that never succeeded in the first place")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dialAttempts))
+}
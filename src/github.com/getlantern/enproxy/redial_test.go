@@ -0,0 +1,56 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveOneThenClose handles exactly one request on conn and replies with
+// "Connection: close", simulating a proxy that's about to recycle the
+// socket and forcing the client to redial for its next request.
+func serveOneThenClose(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestConnectionCloseForcesRedial(t *testing.T) {
+	dialCount := 0
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialCount++
+		client, server := net.Pipe()
+		go serveOneThenClose(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+
+	// Exercise doRequest directly (rather than via Connect/Write) so this
+	// test isn't racing the background processReads goroutine for proxy
+	// dials.
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 2, dialCount, "each request should have redialed after the prior response said Connection: close")
+}
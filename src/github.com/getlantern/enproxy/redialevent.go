@@ -0,0 +1,47 @@
+package enproxy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RedialEvent describes a single redial doRequest performs after a
+// transport-level failure, passed to Config.OnRedial.
+type RedialEvent struct {
+	// Op is the op (opRead, opWrite or opConnect) that failed and is
+	// about to be retried against a freshly dialed proxy connection.
+	Op string
+	// Cause is the error that triggered the redial.
+	Cause error
+	// Attempt is the 1-based count of transport failures seen so far for
+	// this doRequest call, including the one that just happened.
+	Attempt int
+	// Downtime is how long it's been since the Conn last successfully
+	// exchanged a request with the proxy, per Conn.LastActivity.
+	Downtime time.Duration
+
+	// Resumed is always false: this package doesn't implement a resume
+	// feature (see ActiveFeatures.Resume), so a redial never picks up
+	// in-flight state from the connection it's replacing. It's included
+	// so a future resume implementation can report success here without
+	// an incompatible change to this struct.
+	Resumed bool
+}
+
+// fireOnRedial calls c.OnRedial, if set, with a RedialEvent describing the
+// retry doRequest is about to make after a transport failure.
+func (c *Conn) fireOnRedial(op string, cause error, attempt int) {
+	atomic.AddInt64(&c.redials, 1)
+	if c.Metrics != nil {
+		c.Metrics.Redial(cause)
+	}
+	if c.OnRedial == nil {
+		return
+	}
+	c.OnRedial(RedialEvent{
+		Op:       op,
+		Cause:    cause,
+		Attempt:  attempt,
+		Downtime: time.Since(c.lastActivity()),
+	})
+}
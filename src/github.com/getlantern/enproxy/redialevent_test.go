@@ -0,0 +1,60 @@
+package enproxy
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOnRedialFiresOnTransientFailure confirms OnRedial fires with the
+// triggering error and op each time AutoReconnect redials and retries,
+// and not when a request simply succeeds.
+func TestOnRedialFiresOnTransientFailure(t *testing.T) {
+	var failDials int32
+	var events []RedialEvent
+
+	cfg := testConfig()
+	cfg.AutoReconnect = true
+	cfg.OnRedial = func(e RedialEvent) { events = append(events, e) }
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		if atomic.LoadInt32(&failDials) > 0 {
+			atomic.AddInt32(&failDials, -1)
+			return nil, assert.AnError
+		}
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	// Talk to the Conn directly through doRequest, rather than through
+	// Connect/Write, so the background read-polling loop Connect starts
+	// doesn't also redial concurrently and make the attempt count racy.
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Empty(t, events, "a successful request shouldn't fire OnRedial")
+
+	atomic.StoreInt32(&failDials, 2)
+	conn.closeProxyConn()
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	if !assert.Equal(t, 2, len(events)) {
+		t.FailNow()
+	}
+	for i, e := range events {
+		assert.Equal(t, opWrite, e.Op)
+		assert.Error(t, e.Cause)
+		assert.Equal(t, i+1, e.Attempt)
+		assert.False(t, e.Resumed, "this package doesn't implement resume")
+	}
+}
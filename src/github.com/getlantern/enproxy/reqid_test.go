@@ -0,0 +1,97 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reqIDEchoingProxy answers connect and one write op, echoing back whatever
+// XEnproxyReqID it saw on each request, and also reporting it to seenCh for
+// the test to inspect what the client actually sent.
+func reqIDEchoingProxy(conn net.Conn, seenCh chan<- string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for i := 0; i < 2; i++ {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		reqID := req.Header.Get(XEnproxyReqID)
+		seenCh <- reqID
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nX-Enproxy-Req-Id: " + reqID + "\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+// TestReqIDIsFreshPerDoRequestCall verifies every call to doRequest gets its
+// own XEnproxyReqID, distinct from the stable per-tunnel XEnproxyID, so a
+// server can trace one specific request rather than just the tunnel as a
+// whole (see TestIDStableAcrossAllOpsOfATunnel for the complementary
+// per-tunnel id).
+func TestReqIDIsFreshPerDoRequestCall(t *testing.T) {
+	seenCh := make(chan string, 2)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go reqIDEchoingProxy(server, seenCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	connectReqID := <-seenCh
+	writeReqID := <-seenCh
+
+	assert.NotEmpty(t, connectReqID)
+	assert.NotEmpty(t, writeReqID)
+	assert.NotEqual(t, connectReqID, writeReqID, "each doRequest call should get its own req id")
+}
+
+// TestOnResponseHeadersSeesReqIDAndHeaders confirms Config.OnResponseHeaders
+// fires with the same req id the request carried and the response's actual
+// headers, for correlating one request's client and server-side logs.
+func TestOnResponseHeadersSeesReqIDAndHeaders(t *testing.T) {
+	type seen struct {
+		op     string
+		reqID  string
+		header http.Header
+	}
+	seenCh := make(chan seen, 1)
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go reqIDEchoingProxy(server, make(chan string, 2))
+		return client, nil
+	}
+	cfg.OnResponseHeaders = func(op string, reqID string, header http.Header) {
+		seenCh <- seen{op, reqID, header}
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	s := <-seenCh
+	assert.Equal(t, opConnect, s.op)
+	assert.NotEmpty(t, s.reqID)
+	assert.Equal(t, s.reqID, s.header.Get(XEnproxyReqID))
+}
@@ -0,0 +1,34 @@
+package enproxy
+
+import "net"
+
+// XEnproxyResolvedDestAddr carries, in a connect response, the address the
+// proxy actually connected to on the destination's end (its RemoteAddr()),
+// for deployments where the proxy does its own DNS resolution and the
+// client would otherwise have no visibility into which IP was reached.
+const XEnproxyResolvedDestAddr = "X-Enproxy-Resolved-Dest-Addr"
+
+// recordResolvedDest captures the resolved destination address the proxy
+// reports in a connect response, if it reports one.
+func (c *Conn) recordResolvedDest(header headerGetter) {
+	resolved := header.Get(XEnproxyResolvedDestAddr)
+	if resolved == "" {
+		return
+	}
+	c.connInfoMutex.Lock()
+	c.resolvedDestAddr = resolved
+	c.connInfoMutex.Unlock()
+}
+
+// ResolvedDestAddr returns the address the proxy reported actually
+// connecting to for this Conn's destination, once known. It's nil until a
+// connect response carrying XEnproxyResolvedDestAddr has been received,
+// for example because the proxy doesn't support reporting it.
+func (c *Conn) ResolvedDestAddr() net.Addr {
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	if c.resolvedDestAddr == "" {
+		return nil
+	}
+	return addr(c.resolvedDestAddr)
+}
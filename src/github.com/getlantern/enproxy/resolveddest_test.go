@@ -0,0 +1,50 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resolvedDestProxy acknowledges the connect request, reporting the IP it
+// claims to have resolved the destination to.
+func resolvedDestProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte(
+		"HTTP/1.1 200 OK\r\n" + XEnproxyResolvedDestAddr + ": 203.0.113.7:80\r\nContent-Length: 0\r\n\r\n"))
+}
+
+func TestResolvedDestAddr(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go resolvedDestProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	assert.Nil(t, conn.ResolvedDestAddr(), "should be nil before any connect response is seen")
+
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	resolved := conn.ResolvedDestAddr()
+	if !assert.NotNil(t, resolved) {
+		t.FailNow()
+	}
+	assert.Equal(t, "203.0.113.7:80", resolved.String())
+	assert.Equal(t, "tcp", resolved.Network())
+}
@@ -0,0 +1,78 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// upperCasingReader uppercases every byte it reads, standing in for a real
+// transform like decryption or a checksum check.
+type upperCasingReader struct {
+	r io.Reader
+}
+
+func (u *upperCasingReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] >= 'a' && p[i] <= 'z' {
+			p[i] -= 'a' - 'A'
+		}
+	}
+	return n, err
+}
+
+func TestResponseBodyTransformAppliesToReads(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go eofAfterOneReadProxy(server, "hello")
+		return client, nil
+	}
+	var sawOp string
+	cfg.ResponseBodyTransform = func(op string, r io.Reader) io.Reader {
+		sawOp = op
+		return &upperCasingReader{r: r}
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	b := make([]byte, 16)
+	n, err := conn.Read(b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "HELLO", string(b[:n]))
+	assert.Equal(t, opRead, sawOp)
+}
+
+// eofAfterOneReadProxy answers the connect op normally, then on the first
+// read op sends payload and signals EOF.
+func eofAfterOneReadProxy(conn net.Conn, payload string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	req, err = http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	resp := "HTTP/1.1 200 OK\r\n" + XEnproxyEOF + ": true\r\nContent-Length: " +
+		strconv.Itoa(len(payload)) + "\r\n\r\n" + payload
+	_, _ = conn.Write([]byte(resp))
+}
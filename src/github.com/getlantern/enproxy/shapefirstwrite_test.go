@@ -0,0 +1,68 @@
+package enproxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingWriteProxy responds 200 OK to every request like serveFakeProxy,
+// but also appends each write op's body to bodies, so a test can inspect
+// exactly what crossed the wire.
+func recordingWriteProxy(conn net.Conn, bodies *[][]byte) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		if req.Header.Get(XEnproxyOp) == opWrite {
+			*bodies = append(*bodies, body)
+		}
+		resp := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+		if _, err := conn.Write([]byte(resp)); err != nil {
+			return
+		}
+	}
+}
+
+// TestShapeFirstWriteAppliesOnlyToFirstWrite confirms ShapeFirstWrite
+// transforms the very first Write's bytes before they're sent, and leaves
+// every later Write on the same Conn untouched.
+func TestShapeFirstWriteAppliesOnlyToFirstWrite(t *testing.T) {
+	var bodies [][]byte
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go recordingWriteProxy(server, &bodies)
+		return client, nil
+	}
+	cfg.ShapeFirstWrite = func(b []byte) []byte {
+		return append([]byte("shaped:"), b...)
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("world"))
+	assert.NoError(t, err)
+
+	if !assert.Len(t, bodies, 2) {
+		t.FailNow()
+	}
+	assert.Equal(t, "shaped:hello", string(bodies[0]))
+	assert.Equal(t, "world", string(bodies[1]))
+}
@@ -0,0 +1,73 @@
+package enproxy
+
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldRetryRetriesTransportFailureUntilItSucceeds(t *testing.T) {
+	var dialAttempts int32
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		n := atomic.AddInt32(&dialAttempts, 1)
+		if n < 3 {
+			return nil, assert.AnError
+		}
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+	cfg.ShouldRetry = func(op string, attempt int, err error, resp *http.Response) bool {
+		return op == opConnect && attempt < 5
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&dialAttempts))
+}
+
+func TestShouldRetryDefaultsToNoRetryOnTransportFailure(t *testing.T) {
+	var dialAttempts int32
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		atomic.AddInt32(&dialAttempts, 1)
+		return nil, assert.AnError
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opConnect, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dialAttempts), "without ShouldRetry, a transport failure should not be retried")
+}
+
+func TestShouldRetryCanDistinguishByOp(t *testing.T) {
+	var writeAttempts int32
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		_ = server.Close()
+		return client, nil
+	}
+	cfg.ShouldRetry = func(op string, attempt int, err error, resp *http.Response) bool {
+		if op == opWrite {
+			atomic.AddInt32(&writeAttempts, 1)
+		}
+		return false
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	_, err := conn.doRequest(opWrite, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&writeAttempts), "ShouldRetry should see the write op even though it declines to retry")
+}
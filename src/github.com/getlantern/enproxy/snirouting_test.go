@@ -0,0 +1,51 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// destAddrCapturingProxy answers the connect op, recording the
+// XEnproxyDestAddr it saw.
+func destAddrCapturingProxy(conn net.Conn, destCh chan<- string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	destCh <- req.Header.Get(XEnproxyDestAddr)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+}
+
+// TestDestAddrNeverOmittedOrPlaceholder verifies the precondition a server
+// needs to fall back to SNI-based routing (request #synth-243's transparent
+// TLS tunneling, which lives server-side and so isn't implemented in this
+// package): this package always sends the real destination address, so a
+// server implementing SNI fallback can safely treat "absent or placeholder"
+// as "this client doesn't support SNI fallback" without ever seeing it from
+// this client.
+func TestDestAddrNeverOmittedOrPlaceholder(t *testing.T) {
+	destCh := make(chan string, 1)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go destAddrCapturingProxy(server, destCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:443")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, "dest.example.com:443", <-destCh)
+}
@@ -0,0 +1,38 @@
+package enproxy
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/proxy"
+)
+
+// SOCKSAuth carries SOCKS5 username/password credentials. A nil *SOCKSAuth
+// means no authentication.
+type SOCKSAuth struct {
+	Username string
+	Password string
+}
+
+// DialViaSOCKS5 returns a Config.DialProxy function that reaches the
+// enproxy edge at socksAddr through a SOCKS5 proxy, for environments where
+// egress is forced through one. auth may be nil if the SOCKS5 proxy
+// requires no authentication.
+func DialViaSOCKS5(socksAddr string, auth *SOCKSAuth) func(addr string) (net.Conn, error) {
+	var socksProxyAuth *proxy.Auth
+	if auth != nil {
+		socksProxyAuth = &proxy.Auth{User: auth.Username, Password: auth.Password}
+	}
+
+	return func(addr string) (net.Conn, error) {
+		dialer, err := proxy.SOCKS5("tcp", socksAddr, socksProxyAuth, proxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to build SOCKS5 dialer for %v: %v", socksAddr, err)
+		}
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to reach %v via SOCKS5 proxy %v: %v", addr, socksAddr, err)
+		}
+		return conn, nil
+	}
+}
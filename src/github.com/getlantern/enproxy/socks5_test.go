@@ -0,0 +1,80 @@
+package enproxy
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveMinimalSOCKS5 accepts one connection and performs just enough of the
+// SOCKS5 handshake (no-auth, CONNECT) to prove a client reached it and asked
+// for the right destination, then pipes the connection to upstream.
+func serveMinimalSOCKS5(t *testing.T, ln net.Listener, upstream net.Conn) {
+	conn, err := ln.Accept()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Greeting: version, nmethods, methods...
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); !assert.NoError(t, err) {
+		return
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(conn, methods); !assert.NoError(t, err) {
+		return
+	}
+	if _, err := conn.Write([]byte{5, 0}); !assert.NoError(t, err) { // version 5, no auth
+		return
+	}
+
+	// Request: version, cmd, rsv, atyp, addr..., port(2)
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); !assert.NoError(t, err) {
+		return
+	}
+	switch header[3] {
+	case 1: // IPv4
+		addr := make([]byte, 4+2)
+		_, _ = io.ReadFull(conn, addr)
+	case 3: // domain name
+		lenBuf := make([]byte, 1)
+		_, _ = io.ReadFull(conn, lenBuf)
+		addr := make([]byte, int(lenBuf[0])+2)
+		_, _ = io.ReadFull(conn, addr)
+	}
+
+	// Reply: version, success, rsv, atyp, bind addr(4), bind port(2)
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); !assert.NoError(t, err) {
+		return
+	}
+
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+}
+
+func TestDialViaSOCKS5(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = ln.Close() }()
+
+	upstreamClient, upstreamServer := net.Pipe()
+	go serveMinimalSOCKS5(t, ln, upstreamServer)
+
+	dial := DialViaSOCKS5(ln.Addr().String(), nil)
+	conn, err := dial("edge.example.com:80")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	go func() { _, _ = conn.Write([]byte("ping")) }()
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(upstreamClient, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
@@ -0,0 +1,110 @@
+package enproxy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Reason identifies why a Conn closed, for Config.OnClose.
+type Reason string
+
+const (
+	// ReasonExplicit means the application called Close.
+	ReasonExplicit Reason = "explicit"
+
+	// ReasonError means an unrecoverable error occurred reading from or
+	// writing to the proxy.
+	ReasonError Reason = "error"
+
+	// ReasonIdle means the Conn was torn down after exceeding
+	// Config.IdleTimeout.
+	ReasonIdle Reason = "idle"
+
+	// ReasonRedialExhausted means the Conn gave up after repeatedly
+	// failing to redial the proxy.
+	ReasonRedialExhausted Reason = "redial-exhausted"
+
+	// ReasonUnreadData means the Conn closed under UnreadDataClose after
+	// Config.UnreadDataTimeout elapsed with response data the app never
+	// read.
+	ReasonUnreadData Reason = "unread-data"
+)
+
+// ConnStats summarizes a Conn's lifetime, reported once via Config.OnClose
+// when it closes.
+type ConnStats struct {
+	// BytesRead is the total number of bytes Read returned to the caller.
+	BytesRead int64
+
+	// BytesWritten is the total number of bytes passed to Write.
+	BytesWritten int64
+
+	// Duration is how long the Conn was connected, from a successful
+	// Connect to close.
+	Duration time.Duration
+
+	// ErrorCounts breaks down failures observed over the Conn's lifetime by
+	// op and category, for monitoring.
+	ErrorCounts ErrorCounts `json:"errorCounts"`
+
+	// EstablishmentTimings breaks down how long each phase of connecting
+	// took; see Conn.EstablishmentTimings. The zero value until Connect
+	// has completed.
+	EstablishmentTimings Timings `json:"establishmentTimings"`
+
+	// TransportKind is the transport the Conn was using when stats were
+	// taken; see Conn.TransportKind.
+	TransportKind TransportKind `json:"transportKind"`
+
+	// WriteQueueDelay is a moving average of time Write calls have spent
+	// waiting for a free write slot, as of when stats were taken; see
+	// Conn.WriteQueueDelay.
+	WriteQueueDelay time.Duration `json:"writeQueueDelay"`
+
+	// RequestsStarted is the total number of doRequest calls issued so
+	// far, one per call; retries within a call aren't counted again (see
+	// Redials).
+	RequestsStarted int64 `json:"requestsStarted"`
+
+	// Redials is the total number of transport-retries doRequest has
+	// performed so far after a failed attempt; see Config.OnRedial.
+	Redials int64 `json:"redials"`
+
+	// Idle is how long it's been since the proxy connection was last used,
+	// per Conn.LastActivity. Zero until the first request completes.
+	Idle time.Duration `json:"idle"`
+}
+
+// recordBytesRead adds to c's read byte counter.
+func (c *Conn) recordBytesRead(n int) {
+	atomic.AddInt64(&c.bytesRead, int64(n))
+}
+
+// recordBytesWritten adds to c's write byte counter.
+func (c *Conn) recordBytesWritten(n int) {
+	atomic.AddInt64(&c.bytesWritten, int64(n))
+}
+
+// stats snapshots c's current ConnStats.
+func (c *Conn) stats() ConnStats {
+	var duration time.Duration
+	if !c.connectedAt.IsZero() {
+		duration = time.Since(c.connectedAt)
+	}
+	var idle time.Duration
+	if lastActivity := c.lastActivity(); !lastActivity.IsZero() {
+		idle = time.Since(lastActivity)
+	}
+	return ConnStats{
+		BytesRead:            atomic.LoadInt64(&c.bytesRead),
+		BytesWritten:         atomic.LoadInt64(&c.bytesWritten),
+		Duration:             duration,
+		ErrorCounts:          c.errorCounts.snapshot(),
+		EstablishmentTimings: c.EstablishmentTimings(),
+		TransportKind:        c.TransportKind(),
+		WriteQueueDelay:      c.WriteQueueDelay(),
+		RequestsStarted:      atomic.LoadInt64(&c.requestsStarted),
+		Redials:              atomic.LoadInt64(&c.redials),
+		Idle:                 idle,
+	}
+}
@@ -0,0 +1,68 @@
+package enproxy
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnCloseFiresOnceOnExplicitClose(t *testing.T) {
+	cfg := testConfig()
+	var mu sync.Mutex
+	var calls int
+	var lastReason Reason
+	cfg.OnClose = func(stats ConnStats, reason Reason) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		lastReason = reason
+	}
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	_ = conn.Close()
+	_ = conn.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, calls, "OnClose should fire exactly once even under repeated Close calls")
+	assert.Equal(t, ReasonExplicit, lastReason)
+}
+
+func TestStatsTrackBytesReadAndWritten(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	// Connect directly via doRequest rather than conn.Connect, so the
+	// background processReads loop it would start doesn't race the
+	// write op below over the same unserialized net.Pipe.
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+	defer func() { _ = conn.Close() }()
+
+	n, err := conn.Write([]byte("hello"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 5, n)
+
+	stats := conn.stats()
+	assert.Equal(t, int64(5), stats.BytesWritten)
+}
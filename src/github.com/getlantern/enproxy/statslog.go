@@ -0,0 +1,54 @@
+package enproxy
+
+import (
+	"fmt"
+	"time"
+)
+
+// maybeStartStatsLog starts the periodic stats logger if Config.Log and
+// Config.StatsLogInterval are both set, for passive visibility into a
+// long-running Conn without the caller having to snapshot stats itself.
+func (c *Conn) maybeStartStatsLog() {
+	if c.Log == nil || c.StatsLogInterval <= 0 {
+		return
+	}
+	c.goroutineStarted()
+	go c.logStatsPeriodically()
+}
+
+// logStatsPeriodically calls Config.Log with a formatted stats summary every
+// StatsLogInterval until c closes.
+func (c *Conn) logStatsPeriodically() {
+	defer c.goroutineStopped()
+
+	ticker := time.NewTicker(c.StatsLogInterval)
+	defer ticker.Stop()
+
+	prev := c.stats()
+	prevAt := time.Now()
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case now := <-ticker.C:
+			current := c.stats()
+			c.Log(formatStatsLine(current, prev, now.Sub(prevAt)))
+			prev = current
+			prevAt = now
+		}
+	}
+}
+
+// formatStatsLine renders a one-line stats summary for Config.Log, reporting
+// cumulative counts alongside a throughput figure computed from what changed
+// between prev and current over elapsed.
+func formatStatsLine(current, prev ConnStats, elapsed time.Duration) string {
+	var throughput float64
+	if elapsed > 0 {
+		deltaBytes := (current.BytesRead + current.BytesWritten) - (prev.BytesRead + prev.BytesWritten)
+		throughput = float64(deltaBytes) / elapsed.Seconds()
+	}
+	return fmt.Sprintf(
+		"enproxy: bytesRead=%d bytesWritten=%d requests=%d redials=%d throughput=%.0fB/s idle=%s",
+		current.BytesRead, current.BytesWritten, current.RequestsStarted, current.Redials, throughput, current.Idle)
+}
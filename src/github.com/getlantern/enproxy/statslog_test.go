@@ -0,0 +1,71 @@
+package enproxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStatsLogFiresAtIntervalAndStopsAfterClose confirms Config.Log is
+// called periodically once StatsLogInterval elapses, and that it stops being
+// called once Close returns.
+func TestStatsLogFiresAtIntervalAndStopsAfterClose(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+	cfg := testConfig()
+	cfg.StatsLogInterval = 5 * time.Millisecond
+	cfg.Log = func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, line)
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		mu.Lock()
+		n := len(lines)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("Log was never called at the configured interval")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	assert.NoError(t, conn.Close())
+
+	mu.Lock()
+	countAtClose := len(lines)
+	mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, countAtClose, len(lines), "Log shouldn't be called again after Close")
+}
+
+// TestStatsLogDisabledByDefault confirms a zero StatsLogInterval never
+// calls Log.
+func TestStatsLogDisabledByDefault(t *testing.T) {
+	called := false
+	cfg := testConfig()
+	cfg.Log = func(line string) { called = true }
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, called)
+}
@@ -0,0 +1,78 @@
+package enproxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// maxStreamCompressionDict is the largest preset dictionary kept per
+// direction, matching DEFLATE's maximum window size; older bytes beyond
+// this are dropped since DEFLATE couldn't reference them anyway.
+const maxStreamCompressionDict = 32 * 1024
+
+// compressPayload DEFLATE-compresses payload using the current write
+// dictionary, then extends that dictionary with payload so the next frame
+// can reference it.
+func (c *Conn) compressPayload(payload []byte) ([]byte, error) {
+	if len(payload) == 0 {
+		// Nothing to compress, and nothing to add to the dictionary either.
+		return nil, nil
+	}
+
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	if c.ci == nil {
+		return nil, fmt.Errorf("no proxy connection to compress for")
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, flate.DefaultCompression, c.ci.writeDict)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to construct compressor: %v", err)
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, fmt.Errorf("Unable to compress payload: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("Unable to flush compressor: %v", err)
+	}
+
+	c.ci.writeDict = extendStreamDict(c.ci.writeDict, payload)
+	return buf.Bytes(), nil
+}
+
+// decompressPayload inflates compressed using the current read dictionary,
+// then extends that dictionary with the decompressed result.
+func (c *Conn) decompressPayload(compressed []byte) ([]byte, error) {
+	if len(compressed) == 0 {
+		return nil, nil
+	}
+
+	c.connInfoMutex.Lock()
+	defer c.connInfoMutex.Unlock()
+	if c.ci == nil {
+		return nil, fmt.Errorf("no proxy connection to decompress for")
+	}
+
+	r := flate.NewReaderDict(bytes.NewReader(compressed), c.ci.readDict)
+	defer func() { _ = r.Close() }()
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decompress payload: %v", err)
+	}
+
+	c.ci.readDict = extendStreamDict(c.ci.readDict, payload)
+	return payload, nil
+}
+
+// extendStreamDict appends data to dict, keeping only the trailing
+// maxStreamCompressionDict bytes.
+func extendStreamDict(dict []byte, data []byte) []byte {
+	dict = append(dict, data...)
+	if len(dict) > maxStreamCompressionDict {
+		dict = dict[len(dict)-maxStreamCompressionDict:]
+	}
+	return dict
+}
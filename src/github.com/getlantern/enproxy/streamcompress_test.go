@@ -0,0 +1,173 @@
+package enproxy
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// compactStreamCompressionProxy mirrors compactFramingProxy, but also
+// mirrors the client's per-direction DEFLATE dictionaries so it can
+// decompress incoming write payloads and compress outgoing read payloads
+// using the same scheme as Config.StreamCompression.
+func compactStreamCompressionProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	if req.Header.Get(compactUpgradeHeader) == "" {
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+		return
+	}
+	_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" + compactUpgradeHeader + ": 1\r\n\r\n"))
+
+	var readDict, writeDict []byte // readDict mirrors the client's writeDict, and vice versa.
+	var lastPayload []byte
+	for {
+		op, _, _, compressed, err := readCompactRequestFrame(reader)
+		if err != nil {
+			return
+		}
+		var payload []byte
+		if len(compressed) > 0 {
+			payload, err = inflateWithDict(compressed, readDict)
+			if err != nil {
+				return
+			}
+			readDict = extendStreamDict(readDict, payload)
+		}
+
+		switch op {
+		case frameWrite:
+			lastPayload = payload
+			_ = writeCompactResponseFrame(conn, frameStatusOK, nil)
+		case frameRead:
+			var out []byte
+			if len(lastPayload) > 0 {
+				out, err = deflateWithDict(lastPayload, writeDict)
+				if err != nil {
+					return
+				}
+				writeDict = extendStreamDict(writeDict, lastPayload)
+			}
+			lastPayload = nil
+			_ = writeCompactResponseFrame(conn, frameStatusOK, out)
+		default:
+			_ = writeCompactResponseFrame(conn, frameStatusOK, nil)
+		}
+	}
+}
+
+func deflateWithDict(payload, dict []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, flate.DefaultCompression, dict)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func inflateWithDict(compressed, dict []byte) ([]byte, error) {
+	r := flate.NewReaderDict(bytes.NewReader(compressed), dict)
+	defer func() { _ = r.Close() }()
+	return io.ReadAll(r)
+}
+
+func TestStreamCompressionRoundTrip(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.StreamCompression = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go compactStreamCompressionProxy(server)
+		return client, nil
+	}
+
+	// doRequest(opConnect, ...) directly, rather than Connect, so there's
+	// no background processReads goroutine issuing its own read ops
+	// against the same fake proxy concurrently with the ones below; redo
+	// just the bits of Connect that matter here (settling the compact
+	// framing upgrade) by hand.
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	conn.tryUpgradeToCompactFraming(connResp)
+	_ = connResp.Body.Close()
+	assert.True(t, conn.isUpgradedToCompactFraming())
+
+	payload := []byte("the quick brown fox jumps over the lazy dog, repeatedly")
+	for i := 0; i < 5; i++ {
+		_, err := conn.doRequest(opWrite, bytes.NewReader(payload))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		resp, err := conn.doRequest(opRead, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		echoed, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, payload, echoed, "payload should round-trip intact through compress/decompress on both ends")
+	}
+}
+
+func TestStreamCompressionBeatsPerRequestForRepetitiveData(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.StreamCompression = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go compactStreamCompressionProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	conn.tryUpgradeToCompactFraming(connResp)
+	_ = connResp.Body.Close()
+	defer func() { _ = conn.Close() }()
+
+	payload := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 20)
+
+	var streamedBytes, perRequestBytes int
+	for i := 0; i < 10; i++ {
+		compressed, err := conn.compressPayload(payload)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		streamedBytes += len(compressed)
+
+		fresh, err := deflateWithDict(payload, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		perRequestBytes += len(fresh)
+	}
+
+	assert.True(t, streamedBytes < perRequestBytes,
+		"reusing the dictionary across requests should compress repetitive payloads better than compressing each one cold")
+}
@@ -0,0 +1,55 @@
+package enproxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// SyncRoundTrip performs a single connect/write/read/close exchange with
+// addr through config's proxy, entirely on the calling goroutine. It's
+// meant for lightweight, one-shot callers -- a CLI tool or a serverless
+// function handling one request -- that would rather pay the cost of a
+// single round trip than that of the background goroutine processReads
+// spawns on every ordinary Conn to stream reads as they arrive.
+//
+// It calls doRequest directly for each op rather than going through
+// Connect, Write, Read and Close, so it never starts that goroutine or any
+// of the others a long-lived Conn can spawn in response to a redial or
+// half-close. The trade-off is no streaming: SyncRoundTrip reads exactly
+// one response to completion and returns it as a single []byte, so it's
+// the wrong fit for a destination that talks back incrementally or stays
+// open past a single exchange.
+func SyncRoundTrip(addr string, body []byte, config *Config) ([]byte, error) {
+	conn := config.NewConn(addr)
+
+	connResp, err := conn.doRequest(opConnect, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to %v via proxy: %v", addr, err)
+	}
+	_ = connResp.Body.Close()
+
+	writeResp, err := conn.doRequest(opWrite, bytes.NewReader(body))
+	if err != nil {
+		_, _ = conn.doRequest(opClose, nil)
+		return nil, fmt.Errorf("Unable to write to %v via proxy: %v", addr, err)
+	}
+	_ = writeResp.Body.Close()
+
+	readResp, err := conn.doRequest(opRead, nil)
+	if err != nil {
+		_, _ = conn.doRequest(opClose, nil)
+		return nil, fmt.Errorf("Unable to read from %v via proxy: %v", addr, err)
+	}
+	respBody, err := io.ReadAll(readResp.Body)
+	_ = readResp.Body.Close()
+	if err != nil {
+		_, _ = conn.doRequest(opClose, nil)
+		return nil, fmt.Errorf("Unable to read response body from %v via proxy: %v", addr, err)
+	}
+
+	if _, err := conn.doRequest(opClose, nil); err != nil {
+		return respBody, fmt.Errorf("Unable to close %v via proxy: %v", addr, err)
+	}
+	return respBody, nil
+}
@@ -0,0 +1,73 @@
+package enproxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echoingProxy simulates a destination that echoes what it's sent: a write
+// op's body is buffered, and handed back as the very next read op's
+// response body, so SyncRoundTrip's write-then-read round trip can be
+// distinguished from a constant response.
+func echoingProxy(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	var buffered []byte
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		reqBody, _ := io.ReadAll(req.Body)
+
+		var respBody []byte
+		switch req.Header.Get(XEnproxyOp) {
+		case opWrite:
+			buffered = append(buffered, reqBody...)
+		case opRead:
+			respBody = buffered
+			buffered = nil
+		}
+
+		resp := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", len(respBody))
+		if _, err := conn.Write(append([]byte(resp), respBody...)); err != nil {
+			return
+		}
+	}
+}
+
+// TestSyncRoundTripPerformsASingleExchange confirms SyncRoundTrip connects,
+// writes, reads and closes in one call, returning what the echoing proxy
+// sent back for the write.
+func TestSyncRoundTripPerformsASingleExchange(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go echoingProxy(server)
+		return client, nil
+	}
+
+	respBody, err := SyncRoundTrip("dest.example.com:80", []byte("hello"), &cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "hello", string(respBody))
+}
+
+// TestSyncRoundTripPropagatesConnectFailure confirms a failed connect op
+// surfaces as an error rather than proceeding to write.
+func TestSyncRoundTripPropagatesConnectFailure(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		return nil, assert.AnError
+	}
+
+	_, err := SyncRoundTrip("dest.example.com:80", []byte("hello"), &cfg)
+	assert.Error(t, err)
+}
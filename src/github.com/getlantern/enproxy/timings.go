@@ -0,0 +1,72 @@
+package enproxy
+
+import "time"
+
+// Timings breaks down how long each phase of a Conn's establishment took,
+// for pinpointing which phase dominates connect latency -- this varies
+// wildly across networks and edges, so a single end-to-end latency number
+// isn't enough to tell a slow DNS/network path apart from a slow proxy or a
+// slow TLS handshake. See Conn.EstablishmentTimings.
+type Timings struct {
+	// ProxyDial is time spent in Config.DialProxy establishing the
+	// underlying connection to the proxy. Any DNS resolution DialProxy
+	// performs along the way -- e.g. via DialTCP or DialHappyEyeballs -- is
+	// folded into this phase rather than broken out on its own, since
+	// DialProxy is an opaque caller-supplied function and Conn has no
+	// visibility into what it does before returning a net.Conn.
+	ProxyDial time.Duration
+
+	// TLSHandshake is time spent completing the TLS handshake with the
+	// proxy. Zero when Config.TLS isn't set.
+	TLSHandshake time.Duration
+
+	// ConnectOp is time spent on the connect request/response round trip
+	// itself, once the proxy connection (and any TLS) is in place.
+	ConnectOp time.Duration
+}
+
+// Total returns the sum of t's phases.
+func (t Timings) Total() time.Duration {
+	return t.ProxyDial + t.TLSHandshake + t.ConnectOp
+}
+
+// recordProxyDialTiming stashes how long the most recent DialProxy call
+// took, for Connect to fold into establishmentTimings once the connect op
+// finishes. It's overwritten by every redial, not just the first, but only
+// Connect's read of it right after establishment ever turns into a
+// permanent EstablishmentTimings value.
+func (c *Conn) recordProxyDialTiming(d time.Duration) {
+	c.timingsMutex.Lock()
+	c.lastProxyDialDuration = d
+	c.timingsMutex.Unlock()
+}
+
+// recordTLSHandshakeTiming is recordProxyDialTiming's counterpart for the
+// TLS handshake phase.
+func (c *Conn) recordTLSHandshakeTiming(d time.Duration) {
+	c.timingsMutex.Lock()
+	c.lastTLSHandshakeDuration = d
+	c.timingsMutex.Unlock()
+}
+
+// recordEstablishmentTimings combines the most recently recorded dial and
+// TLS phases with connectOp (timed by the caller around the connect op
+// itself) into c's permanent EstablishmentTimings. Called once, by Connect,
+// right after the connect op succeeds.
+func (c *Conn) recordEstablishmentTimings(connectOp time.Duration) {
+	c.timingsMutex.Lock()
+	defer c.timingsMutex.Unlock()
+	c.establishmentTimings = Timings{
+		ProxyDial:    c.lastProxyDialDuration,
+		TLSHandshake: c.lastTLSHandshakeDuration,
+		ConnectOp:    connectOp,
+	}
+}
+
+// EstablishmentTimings returns how long each phase of Connect took, for
+// performance debugging. It's the zero Timings until Connect has completed.
+func (c *Conn) EstablishmentTimings() Timings {
+	c.timingsMutex.Lock()
+	defer c.timingsMutex.Unlock()
+	return c.establishmentTimings
+}
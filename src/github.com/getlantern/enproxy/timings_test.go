@@ -0,0 +1,45 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEstablishmentTimingsPopulatedAfterConnect confirms Connect records a
+// non-zero duration for the proxy dial and connect op phases (TLS isn't in
+// play here, since testConfig doesn't enable it), and that the same
+// breakdown surfaces in ConnStats for OnClose consumers.
+func TestEstablishmentTimingsPopulatedAfterConnect(t *testing.T) {
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		time.Sleep(5 * time.Millisecond)
+		client, server := net.Pipe()
+		go serveFakeProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	timings := conn.EstablishmentTimings()
+	assert.True(t, timings.ProxyDial > 0, "expected a non-zero ProxyDial duration")
+	assert.Equal(t, time.Duration(0), timings.TLSHandshake)
+	assert.True(t, timings.ConnectOp > 0, "expected a non-zero ConnectOp duration")
+	assert.Equal(t, timings.ProxyDial+timings.TLSHandshake+timings.ConnectOp, timings.Total())
+
+	assert.Equal(t, timings, conn.stats().EstablishmentTimings)
+}
+
+// TestEstablishmentTimingsZeroBeforeConnect confirms EstablishmentTimings
+// doesn't report stale or garbage data before Connect has ever run.
+func TestEstablishmentTimingsZeroBeforeConnect(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+	assert.Equal(t, Timings{}, conn.EstablishmentTimings())
+}
@@ -0,0 +1,58 @@
+package enproxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+)
+
+// defaultMinTLSVersion is used when Config.MinTLSVersion is unset.
+const defaultMinTLSVersion = tls.VersionTLS12
+
+// minTLSVersion returns c.MinTLSVersion, or defaultMinTLSVersion if unset.
+func (c *Conn) minTLSVersion() uint16 {
+	if c.MinTLSVersion > 0 {
+		return c.MinTLSVersion
+	}
+	return defaultMinTLSVersion
+}
+
+// tlsConfig lazily builds and caches the *tls.Config used to wrap
+// connections to the proxy, so that ClientCert and friends are assembled
+// once rather than on every redial.
+func (c *Conn) tlsConfig() *tls.Config {
+	c.tlsConfigOnce.Do(func() {
+		cfg := &tls.Config{
+			ServerName: c.Host,
+			MinVersion: c.minTLSVersion(),
+		}
+		if c.ClientCert != nil {
+			cfg.Certificates = []tls.Certificate{*c.ClientCert}
+		}
+		c.cachedTLSConfig = cfg
+	})
+	return c.cachedTLSConfig
+}
+
+// maybeWrapTLS wraps conn in TLS and performs the handshake if c.TLS is set,
+// returning conn unmodified otherwise. Failures to load a client
+// certificate surface as distinct errors from handshake failures, since the
+// former usually indicates a misconfiguration while the latter usually
+// indicates a proxy or network problem.
+func (c *Conn) maybeWrapTLS(conn net.Conn) (net.Conn, error) {
+	if !c.TLS {
+		return conn, nil
+	}
+
+	cfg := c.tlsConfig()
+	if c.ClientCert != nil && len(cfg.Certificates) == 0 {
+		return nil, fmt.Errorf("Unable to use configured client certificate for %v", c.Host)
+	}
+
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("TLS handshake with proxy %v failed: %v", c.Host, err)
+	}
+	return tlsConn, nil
+}
@@ -0,0 +1,30 @@
+package enproxy
+
+// TransportKind identifies the wire transport a Conn is currently using to
+// talk to the proxy, for debugging and observability (for example
+// confirming a network actually allowed CompactFraming rather than the
+// proxy silently falling back). This package only implements the two
+// transports below; HTTP/2 or WebSocket transports, should they ever be
+// added, would get their own constants here.
+type TransportKind string
+
+const (
+	// TransportHTTP1 is the default: each op is its own HTTP/1.1
+	// request/response pair, polled as needed.
+	TransportHTTP1 TransportKind = "http/1.1"
+
+	// TransportCompact is the minimal binary framing Config.CompactFraming
+	// upgrades to once the proxy agrees; see framing.go.
+	TransportCompact TransportKind = "compact"
+)
+
+// TransportKind reports which transport c is currently using. It's accurate
+// immediately after Connect returns and stays so across the one transport
+// change this package supports (the CompactFraming upgrade), which only
+// ever happens once, during Connect, never later.
+func (c *Conn) TransportKind() TransportKind {
+	if c.isUpgradedToCompactFraming() {
+		return TransportCompact
+	}
+	return TransportHTTP1
+}
@@ -0,0 +1,39 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportKindDefaultsToHTTP1(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.Equal(t, TransportHTTP1, conn.TransportKind())
+	assert.Equal(t, TransportHTTP1, conn.stats().TransportKind)
+}
+
+func TestTransportKindReflectsCompactFramingUpgrade(t *testing.T) {
+	cfg := testConfig()
+	cfg.CompactFraming = true
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go compactFramingProxy(server)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+	defer func() { _ = conn.Close() }()
+
+	assert.Equal(t, TransportCompact, conn.TransportKind())
+	assert.Equal(t, TransportCompact, conn.stats().TransportKind)
+}
@@ -0,0 +1,36 @@
+package enproxy
+
+import (
+	"fmt"
+	"time"
+)
+
+// UnreadDataPolicy decides what happens when Config.UnreadDataTimeout
+// elapses with a chunk of response data ready to deliver and no Read call
+// around to take it.
+type UnreadDataPolicy int
+
+const (
+	// UnreadDataClose is the default: the Conn closes with a clear
+	// *UnreadDataTimedOut error, surfaced to a subsequent or concurrent
+	// Read, rather than leaving processReads parked indefinitely.
+	UnreadDataClose UnreadDataPolicy = iota
+
+	// UnreadDataDrop discards the pending chunk and keeps streaming,
+	// trading the dropped data for a Conn that stays usable if the app
+	// eventually does call Read again.
+	UnreadDataDrop
+)
+
+// UnreadDataTimedOut is returned from Read, under UnreadDataClose, once
+// Config.UnreadDataTimeout elapses with response data waiting for a Read
+// call that never came.
+type UnreadDataTimedOut struct {
+	// Waited is Config.UnreadDataTimeout, how long the data sat unread
+	// before the Conn gave up on it.
+	Waited time.Duration
+}
+
+func (e *UnreadDataTimedOut) Error() string {
+	return fmt.Sprintf("response data went unread for %s, app may have stopped calling Read", e.Waited)
+}
@@ -0,0 +1,79 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUnreadDataTimeoutClosesConnByDefault sets up a write-only app: it
+// connects but never calls Read, leaving the proxy's chunk (delivered by
+// stallingProxy, which never finishes its response either) with nowhere to
+// go. With UnreadDataTimeout set and UnreadDataPolicy left at its default
+// (UnreadDataClose), the Conn should close itself rather than leave
+// processReads parked forever.
+func TestUnreadDataTimeoutClosesConnByDefault(t *testing.T) {
+	cfg := testConfig()
+	cfg.UnreadDataTimeout = 50 * time.Millisecond
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go stallingProxy(server)
+		return client, nil
+	}
+
+	var reason Reason
+	closed := make(chan struct{})
+	cfg.OnClose = func(stats ConnStats, r Reason) {
+		reason = r
+		close(closed)
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the Conn to close once UnreadDataTimeout elapsed")
+	}
+	assert.Equal(t, ReasonUnreadData, reason)
+}
+
+// TestUnreadDataDropKeepsConnAliveAndDropsPendingChunk mirrors the above
+// setup but under UnreadDataDrop, where the stuck chunk should simply be
+// discarded rather than tearing the Conn down -- the app that never reads
+// is still the one shortchanged, but a later Read of a fresh response
+// keeps working.
+func TestUnreadDataDropKeepsConnAliveAndDropsPendingChunk(t *testing.T) {
+	cfg := testConfig()
+	cfg.UnreadDataTimeout = 50 * time.Millisecond
+	cfg.UnreadDataPolicy = UnreadDataDrop
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go stallingProxy(server)
+		return client, nil
+	}
+
+	closed := make(chan struct{})
+	cfg.OnClose = func(stats ConnStats, r Reason) { close(closed) }
+
+	conn := cfg.NewConn("dest.example.com:80")
+	if !assert.NoError(t, conn.Connect()) {
+		t.FailNow()
+	}
+
+	// Give the chunk time to be offered, time out, and get dropped.
+	time.Sleep(200 * time.Millisecond)
+
+	select {
+	case <-closed:
+		t.Fatal("UnreadDataDrop should not close the Conn")
+	default:
+	}
+
+	_ = conn.Close()
+}
@@ -0,0 +1,40 @@
+package enproxy
+
+import "sync/atomic"
+
+// defaultUserAgent is presented when neither Config.UserAgent nor
+// Config.UserAgents is set, so the proxy sees something other than the
+// standard library's own telltale default.
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// pickUserAgent settles which User-Agent to present for the request
+// doRequest is about to start: Config.UserAgent if set, otherwise the next
+// of Config.UserAgents in round-robin order, otherwise defaultUserAgent.
+// Every retry of that request reuses the result via c.userAgent, so a
+// single logical request always presents one consistent identity even
+// when Config.UserAgents rotates between requests.
+func (c *Conn) pickUserAgent() {
+	c.uaMutex.Lock()
+	c.currentUA = c.nextUserAgent()
+	c.uaMutex.Unlock()
+}
+
+// nextUserAgent computes the User-Agent pickUserAgent should use next.
+func (c *Conn) nextUserAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	if len(c.UserAgents) == 0 {
+		return defaultUserAgent
+	}
+	idx := atomic.AddUint64(&c.userAgentIdx, 1) - 1
+	return c.UserAgents[idx%uint64(len(c.UserAgents))]
+}
+
+// userAgent returns the User-Agent settled on by the most recent
+// pickUserAgent call.
+func (c *Conn) userAgent() string {
+	c.uaMutex.Lock()
+	defer c.uaMutex.Unlock()
+	return c.currentUA
+}
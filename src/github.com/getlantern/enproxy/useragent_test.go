@@ -0,0 +1,90 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uaCapturingProxy answers every request on conn with a plain 200,
+// recording the User-Agent it saw on each one.
+func uaCapturingProxy(conn net.Conn, uasCh chan<- string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		uasCh <- req.Header.Get("User-Agent")
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestFixedUserAgentOverridesUserAgents(t *testing.T) {
+	uasCh := make(chan string, 1)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go uaCapturingProxy(server, uasCh)
+		return client, nil
+	}
+	cfg.UserAgent = "fixed-agent/1.0"
+	cfg.UserAgents = []string{"should-not-be-used/1.0"}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, "fixed-agent/1.0", <-uasCh)
+}
+
+func TestUserAgentsRotateRoundRobinAcrossRequests(t *testing.T) {
+	uasCh := make(chan string, 3)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go uaCapturingProxy(server, uasCh)
+		return client, nil
+	}
+	cfg.UserAgents = []string{"agent-a", "agent-b"}
+
+	conn := cfg.NewConn("dest.example.com:80")
+
+	for i := 0; i < 3; i++ {
+		resp, err := conn.doRequest(opConnect, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_ = resp.Body.Close()
+	}
+
+	assert.Equal(t, "agent-a", <-uasCh)
+	assert.Equal(t, "agent-b", <-uasCh)
+	assert.Equal(t, "agent-a", <-uasCh)
+}
+
+func TestDefaultUserAgentUsedWhenUnconfigured(t *testing.T) {
+	uasCh := make(chan string, 1)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go uaCapturingProxy(server, uasCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, defaultUserAgent, <-uasCh)
+}
@@ -0,0 +1,17 @@
+package enproxy
+
+import (
+	"net"
+	"time"
+)
+
+// CachedConn describes a proxy connection that Conn is about to reuse for
+// its next request, for Config.ValidateProxyConn to inspect.
+type CachedConn struct {
+	// Conn is the cached connection to the proxy.
+	Conn net.Conn
+
+	// LastActivity is when a request was last successfully sent or
+	// received over Conn.
+	LastActivity time.Time
+}
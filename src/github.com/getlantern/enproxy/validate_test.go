@@ -0,0 +1,62 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveKeepingAlive answers every request it receives on conn with a normal
+// 200, leaving the connection open for reuse, so tests can tell a redial
+// apart from a reused conn by counting dials.
+func serveKeepingAlive(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestValidateProxyConnRejectsCachedConn(t *testing.T) {
+	dialCount := 0
+	validated := 0
+
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		dialCount++
+		client, server := net.Pipe()
+		go serveKeepingAlive(server)
+		return client, nil
+	}
+	cfg.ValidateProxyConn = func(cached CachedConn) bool {
+		validated++
+		// Reject whatever's cached so every request is forced to redial.
+		return false
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	resp, err = conn.doRequest(opWrite, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.Equal(t, 2, dialCount, "rejecting the cached conn should force a redial on every request")
+	assert.Equal(t, 1, validated, "ValidateProxyConn should only be consulted when there's a cached conn to validate")
+}
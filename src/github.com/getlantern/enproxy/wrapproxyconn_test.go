@@ -0,0 +1,48 @@
+package enproxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingConn wraps a net.Conn, counting bytes written through it.
+type countingConn struct {
+	net.Conn
+	written *int
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	*c.written += n
+	return n, err
+}
+
+func TestWrapProxyConnWrapsFreshlyDialedConn(t *testing.T) {
+	written := 0
+	cfg := testConfig()
+	cfg.WrapProxyConn = func(conn net.Conn) net.Conn {
+		return &countingConn{Conn: conn, written: &written}
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+
+	assert.True(t, written > 0, "WrapProxyConn's wrapper should have observed the connect request's bytes")
+}
+
+func TestWrapProxyConnNotCalledWhenUnset(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+
+	resp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = resp.Body.Close()
+}
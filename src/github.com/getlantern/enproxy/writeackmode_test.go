@@ -0,0 +1,141 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingWriteProxy answers every op immediately except a write op, whose
+// response it holds until release is closed, letting a test tell whether
+// Write waited for that response or not. It also records each write op's
+// XEnproxyWriteAckMode header, for a test to assert on.
+func blockingWriteProxy(conn net.Conn, release <-chan struct{}, ackModes *[]string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		if req.Header.Get(XEnproxyOp) == opWrite {
+			*ackModes = append(*ackModes, req.Header.Get(XEnproxyWriteAckMode))
+			<-release
+		}
+		if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+// TestWriteAckProxyReceivedWaitsForResponse confirms the default mode keeps
+// today's behavior: Write doesn't return until the proxy has responded.
+func TestWriteAckProxyReceivedWaitsForResponse(t *testing.T) {
+	release := make(chan struct{})
+	var ackModes []string
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go blockingWriteProxy(server, release, &ackModes)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("hello"))
+		writeDone <- err
+	}()
+
+	select {
+	case <-writeDone:
+		t.Fatal("Write shouldn't return before the proxy responds in the default ack mode")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if !assert.NoError(t, <-writeDone) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{""}, ackModes, "proxy-received shouldn't need to tag the request at all")
+}
+
+// TestWriteAckFireAndForgetReturnsImmediately confirms
+// WriteAckFireAndForget returns while the proxy is still holding back its
+// response to the write op, and that the write itself still goes out
+// without an ack-mode header.
+func TestWriteAckFireAndForgetReturnsImmediately(t *testing.T) {
+	release := make(chan struct{})
+	var ackModes []string
+	cfg := testConfig()
+	cfg.WriteAckMode = WriteAckFireAndForget
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go blockingWriteProxy(server, release, &ackModes)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	n, err := conn.Write([]byte("hello"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 5, n)
+
+	for conn.PendingRequests() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	for conn.PendingRequests() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, []string{""}, ackModes)
+}
+
+// TestWriteAckDestWrittenTagsTheRequest confirms WriteAckDestWritten sends
+// XEnproxyWriteAckMode so a proxy that implements the stronger guarantee
+// knows to honor it, while still waiting for the response like
+// WriteAckProxyReceived against a proxy (like this test's) that doesn't.
+func TestWriteAckDestWrittenTagsTheRequest(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	var ackModes []string
+	cfg := testConfig()
+	cfg.WriteAckMode = WriteAckDestWritten
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go blockingWriteProxy(server, release, &ackModes)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{WriteAckDestWritten}, ackModes)
+}
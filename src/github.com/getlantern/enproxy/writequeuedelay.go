@@ -0,0 +1,42 @@
+package enproxy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// writeQueueDelayWeight is how much a new sample counts toward the moving
+// average, chosen to smooth out a few slow outliers without taking too
+// long to reflect a sustained change.
+const writeQueueDelayWeight = 0.2
+
+// recordWriteQueueDelay folds d, how long a Write call just spent waiting
+// on writeSem before its request could be issued, into the exponential
+// moving average WriteQueueDelay reports. c.writeSem is the closest thing
+// this package has to a write queue -- there's no separate channel writes
+// sit in -- so contending for it (under Config.MaxConcurrentWrites) is
+// where queueing delay actually accrues.
+func (c *Conn) recordWriteQueueDelay(d time.Duration) {
+	for {
+		old := atomic.LoadInt64(&c.writeQueueDelay)
+		var next int64
+		if old == 0 {
+			next = int64(d)
+		} else {
+			next = int64(float64(old)*(1-writeQueueDelayWeight) + float64(d)*writeQueueDelayWeight)
+		}
+		if atomic.CompareAndSwapInt64(&c.writeQueueDelay, old, next) {
+			return
+		}
+	}
+}
+
+// WriteQueueDelay returns a moving average of how long recent Write calls
+// have spent waiting for a free write slot before their request actually
+// went out, for pinpointing whether write latency is coming from this
+// queueing rather than the network itself. It's zero until the first Write
+// completes, and stays at or near zero when Config.MaxConcurrentWrites is
+// unset, since an unbounded writeSem never makes a Write actually wait.
+func (c *Conn) WriteQueueDelay() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.writeQueueDelay))
+}
@@ -0,0 +1,54 @@
+package enproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Both tests below set up the proxy connection via a direct connect op
+// rather than Connect, so as not to leave processReads polling in the
+// background and racing the explicit Write call over the same fake
+// net.Pipe connection.
+
+func TestWriteQueueDelayIsZeroWithoutConcurrencyLimit(t *testing.T) {
+	cfg := testConfig()
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	_, err = conn.Write([]byte("hello"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.True(t, conn.WriteQueueDelay() < 50*time.Millisecond)
+}
+
+func TestWriteQueueDelayReflectsContentionOnMaxConcurrentWrites(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxConcurrentWrites = 1
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	conn.initConcurrencyLimits()
+	conn.writeSem.acquire()
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		conn.writeSem.release()
+	}()
+
+	_, err = conn.Write([]byte("hello"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.True(t, conn.WriteQueueDelay() >= 50*time.Millisecond)
+	assert.Equal(t, conn.WriteQueueDelay(), conn.stats().WriteQueueDelay)
+}
@@ -0,0 +1,18 @@
+package enproxy
+
+import "bytes"
+
+// seqBody decorates a write op's body with the sequence number Write
+// assigned it, so doRequestOnce can surface that number to the proxy as
+// XEnproxyWriteSeq without plumbing it through doRequest/doRequestOnce,
+// which every other op calls too and has no use for it. It still supports
+// seeking, so the existing retry-rewind logic in doRequest keeps working
+// unchanged.
+type seqBody struct {
+	*bytes.Reader
+	seq uint64
+}
+
+func (b *seqBody) WriteSeq() uint64 {
+	return b.seq
+}
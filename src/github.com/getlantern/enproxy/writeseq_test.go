@@ -0,0 +1,66 @@
+package enproxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSeqCapturingProxy answers the connect op, then the given number of
+// write ops, recording each one's XEnproxyWriteSeq header in arrival order.
+func writeSeqCapturingProxy(conn net.Conn, numWrites int, seqsCh chan<- []string) {
+	defer func() { _ = conn.Close() }()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, req.Body)
+	_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+
+	seqs := make([]string, 0, numWrites)
+	for i := 0; i < numWrites; i++ {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			break
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		seqs = append(seqs, req.Header.Get(XEnproxyWriteSeq))
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	}
+	seqsCh <- seqs
+}
+
+// TestWriteSeqIncreasesMonotonicallyAcrossWrites issues writes directly via
+// doRequest, bypassing Connect's background processReads loop, which would
+// otherwise race these write ops with its own concurrent read ops against
+// the same fake proxy.
+func TestWriteSeqIncreasesMonotonicallyAcrossWrites(t *testing.T) {
+	seqsCh := make(chan []string, 1)
+	cfg := testConfig()
+	cfg.DialProxy = func(addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go writeSeqCapturingProxy(server, 3, seqsCh)
+		return client, nil
+	}
+
+	conn := cfg.NewConn("dest.example.com:80")
+	connResp, err := conn.doRequest(opConnect, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_ = connResp.Body.Close()
+
+	for i := 0; i < 3; i++ {
+		if !assert.NoError(t, func() error { _, err := conn.Write([]byte("x")); return err }()) {
+			t.FailNow()
+		}
+	}
+
+	assert.Equal(t, []string{"1", "2", "3"}, <-seqsCh)
+}